@@ -0,0 +1,143 @@
+package alert
+
+import (
+	"sync"
+)
+
+type Level int
+
+const (
+	LevelNone Level = iota
+	// Blitzer ahead, speed is fine
+	LevelApproach
+	// Too fast for the posted limit
+	LevelOverspeed
+	// Too fast with a blitzer ahead
+	LevelWarning
+	// Way too fast, points or a driving ban
+	LevelCritical
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelApproach:
+		return "approach"
+	case LevelOverspeed:
+		return "overspeed"
+	case LevelWarning:
+		return "warning"
+	case LevelCritical:
+		return "critical"
+	}
+	return "none"
+}
+
+type Config struct {
+	// Subtract the German meter tolerance: 3 km/h below 100 km/h, 3 % above
+	Tolerance bool
+	// km/h over the limit (after tolerance) we accept before warning
+	Margin float64
+	// km/h we have to drop below the warning threshold again before the alert ends
+	Hysteresis float64
+	// km/h over the limit (after tolerance) that escalate to critical, 21 km/h costs a point in Germany
+	Critical float64
+}
+
+var DefaultConfig = Config{
+	Tolerance:  true,
+	Margin:     0,
+	Hysteresis: 2,
+	Critical:   21,
+}
+
+// State is what the HUD and audio react on
+type State struct {
+	Level Level
+	// Active limit in km/h, 0 if none
+	Limit int32
+	// km/h over the limit after tolerance, negative if below
+	Over float64
+}
+
+// Hook is called whenever the level changes
+type Hook func(previous Level, state State)
+
+// Engine compares our speed with the active limit
+type Engine struct {
+	Config Config
+
+	mu       sync.Mutex
+	state    State
+	speeding bool
+	hooks    []Hook
+}
+
+func NewEngine(config Config) *Engine {
+	return &Engine{Config: config}
+}
+
+func (e *Engine) AddHook(hook Hook) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hooks = append(e.hooks, hook)
+}
+
+// Returns the speed a speed camera would charge us with after the meter tolerance
+func GetChargedSpeed(speed float64) float64 {
+	if speed < 100 {
+		return speed - 3
+	}
+	return speed * 0.97
+}
+
+// Evaluates speed in km/h against the blitzer limit (0 if there is no blitzer ahead) and the posted limit
+func (e *Engine) Update(speed float64, blitzerLimit int32, postedLimit int32) State {
+	e.mu.Lock()
+
+	limit := postedLimit
+	blitzer := blitzerLimit > 0
+	if blitzer {
+		limit = blitzerLimit
+	}
+
+	charged := speed
+	if e.Config.Tolerance {
+		charged = GetChargedSpeed(speed)
+	}
+	state := State{Limit: limit}
+	if limit > 0 {
+		state.Over = charged - float64(limit)
+
+		// Hysteresis, don't flicker around the threshold
+		threshold := e.Config.Margin
+		if e.speeding {
+			threshold -= e.Config.Hysteresis
+		}
+		e.speeding = state.Over > threshold
+	} else {
+		e.speeding = false
+	}
+
+	switch {
+	case e.speeding && state.Over >= e.Config.Critical:
+		state.Level = LevelCritical
+	case e.speeding && blitzer:
+		state.Level = LevelWarning
+	case e.speeding:
+		state.Level = LevelOverspeed
+	case blitzer:
+		state.Level = LevelApproach
+	}
+
+	previous := e.state.Level
+	e.state = state
+	hooks := e.hooks
+	e.mu.Unlock()
+
+	if previous != state.Level {
+		for _, hook := range hooks {
+			hook(previous, state)
+		}
+	}
+	return state
+}
@@ -0,0 +1,91 @@
+// Package raylib plays the sounds of the Audio package on the raylib audio device. It is its own package because
+// raylib needs cgo, Audio itself builds and tests without.
+package raylib
+
+import (
+	Audio "FesterBlitzer/Audio"
+	"encoding/binary"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+const sampleRate = 44100
+
+// Backend plays generated chimes on the raylib audio device
+type Backend struct {
+	sounds   map[Audio.Sound]rl.Sound
+	speech   rl.Sound
+	speaking bool
+}
+
+// Opens the audio device, call after rl.InitWindow
+func NewBackend() *Backend {
+	rl.InitAudioDevice()
+	// Rising two tone for approach, fast high beeps for overspeed, a triad for section control
+	return &Backend{sounds: map[Audio.Sound]rl.Sound{
+		Audio.SoundApproach:  loadChime([]float64{660, 880}, 0.15),
+		Audio.SoundOverspeed: loadChime([]float64{1320, 0, 1320, 0, 1320}, 0.08),
+		Audio.SoundSection:   loadChime([]float64{523, 659, 784}, 0.12),
+	}}
+}
+
+func (r *Backend) Play(sound Audio.Sound) {
+	rl.PlaySound(r.sounds[sound])
+}
+
+func (r *Backend) Speak(wav []byte) {
+	r.unloadSpeech()
+	wave := rl.LoadWaveFromMemory(".wav", wav, int32(len(wav)))
+	if !rl.IsWaveValid(wave) {
+		print("Could not load announcement\n")
+		return
+	}
+	r.speech = rl.LoadSoundFromWave(wave)
+	rl.UnloadWave(wave)
+	r.speaking = true
+	rl.PlaySound(r.speech)
+}
+
+func (r *Backend) IsSpeaking() bool {
+	return r.speaking && rl.IsSoundPlaying(r.speech)
+}
+
+func (r *Backend) unloadSpeech() {
+	if r.speaking {
+		rl.StopSound(r.speech)
+		rl.UnloadSound(r.speech)
+		r.speaking = false
+	}
+}
+
+func (r *Backend) SetVolume(volume float32) {
+	rl.SetMasterVolume(volume)
+}
+
+func (r *Backend) Close() {
+	r.unloadSpeech()
+	for _, sound := range r.sounds {
+		rl.UnloadSound(sound)
+	}
+	rl.CloseAudioDevice()
+}
+
+// Generates a 16 bit mono sound from a list of tones (0 is a pause), each note seconds long
+func loadChime(tones []float64, note float64) rl.Sound {
+	perNote := int(note * sampleRate)
+	data := make([]byte, 0, len(tones)*perNote*2)
+	for _, tone := range tones {
+		for i := 0; i < perNote; i++ {
+			sample := 0.0
+			if tone > 0 {
+				// fade out every note to avoid clicks
+				envelope := 1 - float64(i)/float64(perNote)
+				sample = math.Sin(2*math.Pi*tone*float64(i)/sampleRate) * envelope * 0.6
+			}
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(sample*math.MaxInt16)))
+		}
+	}
+	wave := rl.NewWave(uint32(len(tones)*perNote), sampleRate, 16, 1, data)
+	return rl.LoadSoundFromWave(wave)
+}
@@ -0,0 +1,179 @@
+package audio
+
+import (
+	Alert "FesterBlitzer/Alert"
+	"sync"
+	"time"
+)
+
+type Sound int
+
+const (
+	// Blitzer ahead, repeats faster the closer it gets
+	SoundApproach Sound = iota
+	SoundOverspeed
+	// Entering a section control or getting too fast in one
+	SoundSection
+)
+
+// Backend makes the actual noise
+type Backend interface {
+	Play(sound Sound)
+	// Plays a rendered announcement, wav is a complete RIFF file
+	Speak(wav []byte)
+	IsSpeaking() bool
+	SetVolume(volume float32)
+	Close()
+}
+
+// Null is a silent backend for headless runs, it remembers what would have been played
+type Null struct {
+	mu     sync.Mutex
+	Played []Sound
+	Spoken [][]byte
+	Volume float32
+}
+
+func (n *Null) Play(sound Sound) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Played = append(n.Played, sound)
+}
+
+func (n *Null) Speak(wav []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Spoken = append(n.Spoken, wav)
+}
+
+func (n *Null) IsSpeaking() bool {
+	return false
+}
+
+func (n *Null) SetVolume(volume float32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Volume = volume
+}
+
+func (n *Null) Close() {}
+
+// Player decides what to play when. Queue and the setters are safe from any goroutine,
+// Update has to run in the render loop because raylib audio lives on the main thread.
+type Player struct {
+	Backend Backend
+
+	mu           sync.Mutex
+	queue        []Sound
+	speech       [][]byte
+	muted        bool
+	volume       float32
+	approach     float64
+	lastApproach time.Time
+}
+
+func NewPlayer(backend Backend, volume float32) *Player {
+	backend.SetVolume(volume)
+	return &Player{Backend: backend, volume: volume, approach: -1}
+}
+
+// Plays sound with the next Update
+func (p *Player) Queue(sound Sound) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, sound)
+}
+
+// Speaks wav with the next Update once the previous announcement is done
+func (p *Player) QueueSpeech(wav []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speech = append(p.speech, wav)
+}
+
+// Sets distance in km to the blitzer ahead, negative if there is none
+func (p *Player) SetApproach(distance float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.approach = distance
+}
+
+func (p *Player) SetVolume(volume float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.volume = volume
+	if !p.muted {
+		p.Backend.SetVolume(volume)
+	}
+}
+
+func (p *Player) ToggleMute() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.muted = !p.muted
+	if p.muted {
+		p.Backend.SetVolume(0)
+	} else {
+		p.Backend.SetVolume(p.volume)
+	}
+}
+
+func (p *Player) IsMuted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.muted
+}
+
+// Returns the pause between approach chimes, 10 s at 1 km down to 1.5 s right before the blitzer
+func getApproachInterval(distance float64) time.Duration {
+	seconds := distance * 10
+	if seconds < 1.5 {
+		seconds = 1.5
+	}
+	if seconds > 10 {
+		seconds = 10
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Plays queued sounds and repeats the approach chime, call once per frame
+func (p *Player) Update(now time.Time) {
+	p.mu.Lock()
+	queue := p.queue
+	p.queue = nil
+	if p.approach >= 0 && now.Sub(p.lastApproach) >= getApproachInterval(p.approach) {
+		queue = append(queue, SoundApproach)
+		p.lastApproach = now
+	}
+	if p.approach < 0 {
+		p.lastApproach = time.Time{}
+	}
+	var speech []byte
+	if len(p.speech) > 0 && !p.Backend.IsSpeaking() {
+		speech = p.speech[0]
+		p.speech = p.speech[1:]
+	}
+	muted := p.muted
+	p.mu.Unlock()
+
+	if muted {
+		return
+	}
+	for _, sound := range queue {
+		p.Backend.Play(sound)
+	}
+	if speech != nil {
+		p.Backend.Speak(speech)
+	}
+}
+
+// Alert hook, chimes when we get too fast
+func (p *Player) OnAlert(previous Alert.Level, state Alert.State) {
+	if state.Level >= Alert.LevelOverspeed && state.Level > previous {
+		p.Queue(SoundOverspeed)
+	}
+}
+
+func (p *Player) Close() {
+	p.Backend.Close()
+}
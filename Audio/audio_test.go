@@ -0,0 +1,123 @@
+package audio
+
+import (
+	Alert "FesterBlitzer/Alert"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlayerQueue(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 0.8)
+	if backend.Volume != 0.8 {
+		t.Errorf("Volume = %v", backend.Volume)
+	}
+	player.Queue(SoundSection)
+	player.Queue(SoundOverspeed)
+	player.Update(time.Now())
+	player.Update(time.Now())
+	if want := []Sound{SoundSection, SoundOverspeed}; !slices.Equal(backend.Played, want) {
+		t.Errorf("Played = %v, want %v", backend.Played, want)
+	}
+}
+
+func TestPlayerApproach(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 1)
+	start := time.Unix(1000, 0)
+
+	// 500 m ahead repeats every 5 s
+	player.SetApproach(0.5)
+	for i := 0; i <= 10; i++ {
+		player.Update(start.Add(time.Duration(i) * time.Second))
+	}
+	if len(backend.Played) != 3 {
+		t.Errorf("%d approach chimes in 10 s at 500 m, want 3", len(backend.Played))
+	}
+
+	player.SetApproach(-1)
+	player.Update(start.Add(20 * time.Second))
+	if len(backend.Played) != 3 {
+		t.Errorf("chime without a blitzer ahead")
+	}
+}
+
+func TestGetApproachInterval(t *testing.T) {
+	tests := map[float64]time.Duration{
+		2:    10 * time.Second,
+		0.5:  5 * time.Second,
+		0.05: 1500 * time.Millisecond,
+	}
+	for distance, want := range tests {
+		if got := getApproachInterval(distance); got != want {
+			t.Errorf("getApproachInterval(%v) = %v, want %v", distance, got, want)
+		}
+	}
+}
+
+func TestPlayerMute(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 0.5)
+	player.ToggleMute()
+	if !player.IsMuted() || backend.Volume != 0 {
+		t.Errorf("muted %v, Volume %v", player.IsMuted(), backend.Volume)
+	}
+	player.Queue(SoundSection)
+	player.QueueSpeech([]byte("wav"))
+	player.Update(time.Now())
+	if len(backend.Played) != 0 || len(backend.Spoken) != 0 {
+		t.Errorf("played %v, spoke %d while muted", backend.Played, len(backend.Spoken))
+	}
+
+	// the volume set while muted comes back on unmute
+	player.SetVolume(0.7)
+	if backend.Volume != 0 {
+		t.Errorf("Volume = %v while muted", backend.Volume)
+	}
+	player.ToggleMute()
+	if player.IsMuted() || backend.Volume != 0.7 {
+		t.Errorf("muted %v, Volume %v", player.IsMuted(), backend.Volume)
+	}
+}
+
+// speakingNull reports it is still speaking until told otherwise
+type speakingNull struct {
+	Null
+	speaking bool
+}
+
+func (s *speakingNull) IsSpeaking() bool {
+	return s.speaking
+}
+
+func TestPlayerSpeech(t *testing.T) {
+	backend := &speakingNull{}
+	player := NewPlayer(backend, 1)
+	player.QueueSpeech([]byte("first"))
+	player.QueueSpeech([]byte("second"))
+
+	player.Update(time.Now())
+	backend.speaking = true
+	player.Update(time.Now())
+	if len(backend.Spoken) != 1 {
+		t.Fatalf("%d announcements while the first one still plays", len(backend.Spoken))
+	}
+	backend.speaking = false
+	player.Update(time.Now())
+	if len(backend.Spoken) != 2 || string(backend.Spoken[1]) != "second" {
+		t.Errorf("Spoken = %q", backend.Spoken)
+	}
+}
+
+func TestPlayerOnAlert(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 1)
+	player.OnAlert(Alert.LevelNone, Alert.State{Level: Alert.LevelOverspeed})
+	// staying too fast doesn't chime again
+	player.OnAlert(Alert.LevelOverspeed, Alert.State{Level: Alert.LevelOverspeed})
+	player.Update(time.Now())
+	if want := []Sound{SoundOverspeed}; !slices.Equal(backend.Played, want) {
+		t.Errorf("Played = %v, want %v", backend.Played, want)
+	}
+}
@@ -0,0 +1,194 @@
+package audio
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Phrase is one announcement, Text for TTS programs and Tokens for pre-recorded snippets
+type Phrase struct {
+	Text   string
+	Tokens []string
+}
+
+// Phrases are the words of one language
+type Phrases struct {
+	Lang  string
+	Types map[Blitzer.Type]string
+	In    string
+	// "Metern" in German, "meters" in English
+	Meters     string
+	Kilometer  string
+	Kilometers string
+}
+
+var German = Phrases{
+	Lang: "de",
+	Types: map[Blitzer.Type]string{
+		Blitzer.TypeUnknown:        "Blitzer",
+		Blitzer.TypeRedLight:       "Ampelblitzer",
+		Blitzer.TypeRedLightSpeed:  "Ampelblitzer",
+		Blitzer.TypeSectionControl: "Abschnittskontrolle",
+		Blitzer.TypeDistance:       "Abstandskontrolle",
+		Blitzer.TypeMobile:         "Mobiler Blitzer",
+		Blitzer.TypeSemiStationary: "Blitzeranhänger",
+		Blitzer.TypeTrafficJamEnd:  "Stauende",
+		Blitzer.TypeHazard:         "Gefahrenstelle",
+	},
+	In:         "in",
+	Meters:     "Metern",
+	Kilometer:  "Kilometer",
+	Kilometers: "Kilometern",
+}
+
+var English = Phrases{
+	Lang: "en",
+	Types: map[Blitzer.Type]string{
+		Blitzer.TypeUnknown:        "Speed camera",
+		Blitzer.TypeRedLight:       "Red light camera",
+		Blitzer.TypeRedLightSpeed:  "Red light camera",
+		Blitzer.TypeSectionControl: "Section control",
+		Blitzer.TypeDistance:       "Distance check",
+		Blitzer.TypeMobile:         "Mobile speed camera",
+		Blitzer.TypeSemiStationary: "Speed camera trailer",
+		Blitzer.TypeTrafficJamEnd:  "Traffic jam",
+		Blitzer.TypeHazard:         "Hazard",
+	},
+	In:         "in",
+	Meters:     "meters",
+	Kilometer:  "kilometer",
+	Kilometers: "kilometers",
+}
+
+// Returns the phrases for "de" or "en", German if unknown
+func GetPhrases(lang string) Phrases {
+	if lang == "en" {
+		return English
+	}
+	return German
+}
+
+// Returns e.g. "Blitzer in 500 Metern, 70, Hauptstraße". Distances are rounded to 100 m below 1 km
+// and to whole km above, so a small set of number snippets is enough. The street is only in Text.
+func (p Phrases) Announce(blitzer Blitzer.Blitzer) Phrase {
+	name, ok := p.Types[blitzer.Type]
+	if !ok {
+		name = p.Types[Blitzer.TypeUnknown]
+	}
+
+	var number int
+	var unit string
+	if blitzer.Distance < 0.95 {
+		number = max(1, int(blitzer.Distance*10+0.5)) * 100
+		unit = p.Meters
+	} else {
+		number = int(blitzer.Distance + 0.5)
+		unit = p.Kilometers
+		if number == 1 {
+			unit = p.Kilometer
+		}
+	}
+
+	words := []string{name, p.In, strconv.Itoa(number), unit}
+	// Red lights, traffic jams and the like have no limit to say
+	if blitzer.Vmax > 0 {
+		words = append(words, ",", strconv.Itoa(int(blitzer.Vmax)))
+	}
+
+	phrase := Phrase{}
+	for _, word := range words {
+		phrase.Tokens = append(phrase.Tokens, getToken(word))
+		if word == "," {
+			phrase.Text += ","
+		} else if phrase.Text == "" {
+			phrase.Text = word
+		} else {
+			phrase.Text += " " + word
+		}
+	}
+	if blitzer.Street != "" {
+		phrase.Text += ", " + blitzer.Street
+	}
+	return phrase
+}
+
+// Returns the snippet file name for a word, "Mobiler Blitzer" is mobiler_blitzer
+func getToken(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), " ", "_")
+}
+
+// Distances in km at which a blitzer is announced, once each
+var announceAt = []float64{1.0, 0.3}
+
+// Announcer speaks approaching blitzers. Rendering runs in its own goroutine because TTS programs take a while.
+type Announcer struct {
+	Voice   Voice
+	Phrases Phrases
+	Player  *Player
+
+	pending   chan Phrase
+	announced map[string]int
+}
+
+func NewAnnouncer(voice Voice, phrases Phrases, player *Player) *Announcer {
+	a := &Announcer{
+		Voice:     voice,
+		Phrases:   phrases,
+		Player:    player,
+		pending:   make(chan Phrase, 1),
+		announced: map[string]int{},
+	}
+	go a.render()
+	return a
+}
+
+// Announces the blitzer when it crosses one of the announce distances, call with every new closest blitzer
+func (a *Announcer) Update(blitzer Blitzer.Blitzer) {
+	if blitzer.Vmax < 0 || (blitzer.Vmax == 0 && blitzer.Type == Blitzer.TypeUnknown) {
+		return
+	}
+	stage := 0
+	for stage < len(announceAt) && blitzer.Distance <= announceAt[stage] {
+		stage++
+	}
+	key := getKey(blitzer)
+	if stage <= a.announced[key] {
+		return
+	}
+	// Forget blitzers we passed long ago
+	if len(a.announced) > 100 {
+		a.announced = map[string]int{}
+	}
+	a.announced[key] = stage
+	a.Say(a.Phrases.Announce(blitzer))
+}
+
+// Returns what tells blitzers apart, the offline database has no IDs so its blitzers go by position
+func getKey(blitzer Blitzer.Blitzer) string {
+	if blitzer.ID != "" {
+		return blitzer.ID
+	}
+	return fmt.Sprintf("%.5f,%.5f", blitzer.Pos[0], blitzer.Pos[1])
+}
+
+// Queues a phrase, an older one that is still waiting gets dropped since its distance is outdated
+func (a *Announcer) Say(phrase Phrase) {
+	select {
+	case <-a.pending:
+	default:
+	}
+	a.pending <- phrase
+}
+
+func (a *Announcer) render() {
+	for phrase := range a.pending {
+		wav, err := a.Voice.Render(phrase)
+		if err != nil {
+			print("Announcement failed: ", err.Error(), "\n")
+			continue
+		}
+		a.Player.QueueSpeech(wav)
+	}
+}
@@ -0,0 +1,112 @@
+package audio
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAnnounce(t *testing.T) {
+	tests := []struct {
+		phrases Phrases
+		blitzer Blitzer.Blitzer
+		text    string
+		tokens  []string
+	}{
+		{German, Blitzer.Blitzer{Distance: 0.48, Vmax: 70}, "Blitzer in 500 Metern, 70", []string{"blitzer", "in", "500", "metern", ",", "70"}},
+		{German, Blitzer.Blitzer{Distance: 1.2, Vmax: 100, Type: Blitzer.TypeMobile, Street: "B27"},
+			"Mobiler Blitzer in 1 Kilometer, 100, B27", []string{"mobiler_blitzer", "in", "1", "kilometer", ",", "100"}},
+		{German, Blitzer.Blitzer{Distance: 0.02, Type: Blitzer.TypeRedLight}, "Ampelblitzer in 100 Metern", []string{"ampelblitzer", "in", "100", "metern"}},
+		{English, Blitzer.Blitzer{Distance: 2.6, Vmax: 80, Type: Blitzer.TypeSectionControl},
+			"Section control in 3 kilometers, 80", []string{"section_control", "in", "3", "kilometers", ",", "80"}},
+	}
+	for _, test := range tests {
+		phrase := test.phrases.Announce(test.blitzer)
+		if phrase.Text != test.text || !slices.Equal(phrase.Tokens, test.tokens) {
+			t.Errorf("got %q %v, want %q %v", phrase.Text, phrase.Tokens, test.text, test.tokens)
+		}
+	}
+}
+
+// textVoice "renders" the text itself
+type textVoice struct{}
+
+func (textVoice) Render(phrase Phrase) ([]byte, error) {
+	return []byte(phrase.Text), nil
+}
+
+// Returns the announcements spoken within a short while
+func getSpoken(t *testing.T, player *Player, backend *Null, want int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		player.Update(time.Now())
+		backend.mu.Lock()
+		count := len(backend.Spoken)
+		backend.mu.Unlock()
+		if count >= want {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// give a wrong extra announcement a chance to show up
+	time.Sleep(20 * time.Millisecond)
+	player.Update(time.Now())
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	spoken := []string{}
+	for _, wav := range backend.Spoken {
+		spoken = append(spoken, string(wav))
+	}
+	return spoken
+}
+
+func TestAnnouncer(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 1)
+	announcer := NewAnnouncer(textVoice{}, German, player)
+
+	blitzer := Blitzer.Blitzer{ID: "1", Vmax: 50}
+	for _, distance := range []float64{1.5, 0.9, 0.8} {
+		blitzer.Distance = distance
+		announcer.Update(blitzer)
+	}
+	if spoken := getSpoken(t, player, backend, 1); !slices.Equal(spoken, []string{"Blitzer in 900 Metern, 50"}) {
+		t.Fatalf("Spoken = %q", spoken)
+	}
+
+	blitzer.Distance = 0.25
+	announcer.Update(blitzer)
+	announcer.Update(blitzer)
+	if spoken := getSpoken(t, player, backend, 2); len(spoken) != 2 || spoken[1] != "Blitzer in 300 Metern, 50" {
+		t.Errorf("Spoken = %q", spoken)
+	}
+}
+
+func TestAnnouncerWithoutID(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 1)
+	announcer := NewAnnouncer(textVoice{}, German, player)
+
+	// the offline database has no IDs, two blitzers in a row must both be announced
+	announcer.Update(Blitzer.Blitzer{Pos: [2]float64{48.52, 8.86}, Distance: 0.9, Vmax: 50})
+	getSpoken(t, player, backend, 1)
+	announcer.Update(Blitzer.Blitzer{Pos: [2]float64{48.53, 8.86}, Distance: 0.9, Vmax: 70})
+	if spoken := getSpoken(t, player, backend, 2); len(spoken) != 2 {
+		t.Errorf("Spoken = %q", spoken)
+	}
+}
+
+func TestAnnouncerSkips(t *testing.T) {
+	backend := &Null{}
+	player := NewPlayer(backend, 1)
+	announcer := NewAnnouncer(textVoice{}, German, player)
+
+	// no data and no blitzer
+	announcer.Update(Blitzer.Blitzer{Vmax: -1})
+	announcer.Update(Blitzer.Blitzer{Distance: 0.2})
+	if spoken := getSpoken(t, player, backend, 0); len(spoken) != 0 {
+		t.Errorf("Spoken = %q", spoken)
+	}
+}
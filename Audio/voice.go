@@ -0,0 +1,180 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var ErrWave = errors.New("audio: not a PCM wav file")
+
+// Voice renders a phrase into a wav file
+type Voice interface {
+	Render(phrase Phrase) ([]byte, error)
+}
+
+// Command runs a local TTS program that writes a wav to stdout.
+// "{text}" in Args is replaced by the phrase, without it the phrase goes to stdin.
+type Command struct {
+	Path string
+	Args []string
+}
+
+// espeak-ng with a voice like "de" or "en"
+func NewEspeak(lang string) *Command {
+	return &Command{Path: "espeak-ng", Args: []string{"-v", lang, "-s", "160", "--stdout", "{text}"}}
+}
+
+// piper with a downloaded .onnx model, the language comes with the model
+func NewPiper(model string) *Command {
+	return &Command{Path: "piper", Args: []string{"--model", model, "--output_file", "-"}}
+}
+
+func (c *Command) Render(phrase Phrase) ([]byte, error) {
+	args := make([]string, len(c.Args))
+	stdin := true
+	for i, arg := range c.Args {
+		if strings.Contains(arg, "{text}") {
+			stdin = false
+		}
+		args[i] = strings.ReplaceAll(arg, "{text}", phrase.Text)
+	}
+	cmd := exec.Command(c.Path, args...)
+	if stdin {
+		cmd.Stdin = strings.NewReader(phrase.Text + "\n")
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	// Written to a pipe the sizes in the header are placeholders, espeak-ng claims almost 2 GB
+	format, data, err := parseWave(output)
+	if err != nil {
+		return nil, fmt.Errorf("audio: %s: %w", c.Path, err)
+	}
+	blockAlign := int(binary.LittleEndian.Uint16(format[12:14]))
+	if blockAlign > 0 {
+		data = data[:len(data)-len(data)%blockAlign]
+	}
+	return writeWave(format, data), nil
+}
+
+// Snippets glues pre-recorded wav files together, one file per token, e.g. de/blitzer.wav, de/500.wav, de/metern.wav.
+// All files need the same format.
+type Snippets struct {
+	Dir string
+	// Silence between sentence parts
+	Pause float64
+}
+
+func NewSnippets(dir string) *Snippets {
+	return &Snippets{Dir: dir, Pause: 0.2}
+}
+
+func (s *Snippets) Render(phrase Phrase) ([]byte, error) {
+	var format []byte
+	var data []byte
+	for _, token := range phrase.Tokens {
+		if token == "," {
+			if format != nil {
+				data = append(data, getSilence(format, s.Pause)...)
+			}
+			continue
+		}
+		file, err := os.ReadFile(filepath.Join(s.Dir, token+".wav"))
+		if err != nil {
+			return nil, err
+		}
+		fmtChunk, dataChunk, err := parseWave(file)
+		if err != nil {
+			return nil, fmt.Errorf("audio: snippet %s: %w", token, err)
+		}
+		if format == nil {
+			format = fmtChunk
+		} else if !bytes.Equal(format, fmtChunk) {
+			return nil, fmt.Errorf("audio: snippet %s has a different format", token)
+		}
+		data = append(data, dataChunk...)
+	}
+	if format == nil {
+		return nil, ErrWave
+	}
+	return writeWave(format, data), nil
+}
+
+// Opens "espeak", "piper:<model.onnx>" or a directory of snippets with one subdirectory per language
+func OpenVoice(spec string, lang string) (Voice, error) {
+	switch {
+	case spec == "espeak":
+		return NewEspeak(lang), nil
+	case strings.HasPrefix(spec, "piper:"):
+		return NewPiper(strings.TrimPrefix(spec, "piper:")), nil
+	}
+	dir := filepath.Join(spec, lang)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("audio: no %s snippets in %s", lang, spec)
+	}
+	return NewSnippets(dir), nil
+}
+
+// Returns the fmt and data chunk of a RIFF wav
+func parseWave(file []byte) ([]byte, []byte, error) {
+	if len(file) < 12 || string(file[0:4]) != "RIFF" || string(file[8:12]) != "WAVE" {
+		return nil, nil, ErrWave
+	}
+	var format []byte
+	for i := 12; i+8 <= len(file); {
+		id := string(file[i : i+4])
+		size := int(binary.LittleEndian.Uint32(file[i+4 : i+8]))
+		start := i + 8
+		end := start + size
+		// TTS programs writing to a pipe can't seek back and leave the size open
+		if end > len(file) {
+			end = len(file)
+		}
+		switch id {
+		case "fmt ":
+			format = file[start:end]
+		case "data":
+			if len(format) < 16 || binary.LittleEndian.Uint16(format[0:2]) != 1 {
+				return nil, nil, ErrWave
+			}
+			return format, file[start:end], nil
+		}
+		// chunks are padded to even sizes
+		i = end + size%2
+	}
+	return nil, nil, ErrWave
+}
+
+func writeWave(format []byte, data []byte) []byte {
+	wav := make([]byte, 0, 20+len(format)+len(data))
+	wav = append(wav, "RIFF"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(4+8+len(format)+8+len(data)))
+	wav = append(wav, "WAVE"...)
+	wav = append(wav, "fmt "...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(format)))
+	wav = append(wav, format...)
+	wav = append(wav, "data"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(data)))
+	return append(wav, data...)
+}
+
+// Returns seconds of silence in the given format, 8 bit samples are unsigned
+func getSilence(format []byte, seconds float64) []byte {
+	rate := binary.LittleEndian.Uint32(format[4:8])
+	blockAlign := int(binary.LittleEndian.Uint16(format[12:14]))
+	bits := binary.LittleEndian.Uint16(format[14:16])
+	silence := make([]byte, int(seconds*float64(rate))*blockAlign)
+	if bits == 8 {
+		for i := range silence {
+			silence[i] = 0x80
+		}
+	}
+	return silence
+}
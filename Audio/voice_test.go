@@ -0,0 +1,73 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 16 bit mono at 22050 Hz
+var testFormat = []byte{1, 0, 1, 0, 0x22, 0x56, 0, 0, 0x44, 0xAC, 0, 0, 2, 0, 16, 0}
+
+// Returns a wav like espeak-ng --stdout writes it to a pipe, the sizes are placeholders
+func getPipedWave(data []byte) []byte {
+	wav := []byte("RIFF")
+	wav = binary.LittleEndian.AppendUint32(wav, 0x7ffff024)
+	wav = append(wav, "WAVEfmt "...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(testFormat)))
+	wav = append(wav, testFormat...)
+	wav = append(wav, "data"...)
+	wav = binary.LittleEndian.AppendUint32(wav, 0x7ffff000)
+	return append(wav, data...)
+}
+
+func TestCommandRenderPipedWave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	// an odd byte at the end, the pipe may cut a sample
+	if err := os.WriteFile(path, getPipedWave([]byte{1, 2, 3, 4, 5}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	command := &Command{Path: "cat", Args: []string{path}}
+	wav, err := command.Render(Phrase{Text: "Blitzer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := writeWave(testFormat, []byte{1, 2, 3, 4}); !bytes.Equal(wav, want) {
+		t.Errorf("got % X\nwant % X", wav, want)
+	}
+	if size := binary.LittleEndian.Uint32(wav[4:8]); int(size) != len(wav)-8 {
+		t.Errorf("RIFF size %d for %d bytes", size, len(wav))
+	}
+}
+
+func TestCommandRenderNoWave(t *testing.T) {
+	// echo writes the text instead of a wav
+	command := &Command{Path: "echo", Args: []string{"{text}"}}
+	if _, err := command.Render(Phrase{Text: "Blitzer"}); err == nil {
+		t.Error("text accepted as wav")
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	dir := t.TempDir()
+	for token, data := range map[string][]byte{"blitzer": {1, 1}, "in": {2, 2}, "70": {3, 3}} {
+		if err := os.WriteFile(filepath.Join(dir, token+".wav"), writeWave(testFormat, data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snippets := &Snippets{Dir: dir, Pause: 0.0001}
+	wav, err := snippets.Render(Phrase{Tokens: []string{"blitzer", "in", ",", "70"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.0001 s at 22050 Hz are 2 samples of silence
+	if want := writeWave(testFormat, []byte{1, 1, 2, 2, 0, 0, 0, 0, 3, 3}); !bytes.Equal(wav, want) {
+		t.Errorf("got % X\nwant % X", wav, want)
+	}
+
+	if _, err := snippets.Render(Phrase{Tokens: []string{"blitzer", "metern"}}); err == nil {
+		t.Error("missing snippet accepted")
+	}
+}
@@ -0,0 +1,223 @@
+package blitzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const AtudoURL = "https://cdn2.atudo.net/api/4.0/pois.php?type=22,26,20,101,102,103,104,105,106,107,108,109,110,111,112,113,115,117,114,ts,0,1,2,3,4,5,6,21,23,24,25,29,vwd,traffic&z=17&box=%f,%f,%f,%f"
+
+var ErrDecode = errors.New("blitzer: could not decode response")
+
+type BlitzerDEResponse struct {
+	Pois []struct {
+		ID      string `json:"id"`
+		Lat     string `json:"lat"`
+		Lng     string `json:"lng"`
+		Address struct {
+			Country      string `json:"country"`
+			State        string `json:"state"`
+			ZipCode      string `json:"zip_code"`
+			City         string `json:"city"`
+			CityDistrict string `json:"city_district"`
+			Street       string `json:"street"`
+		} `json:"address"`
+		Content     string `json:"content"`
+		Backend     string `json:"backend"`
+		Type        string `json:"type"`
+		Vmax        string `json:"vmax"`
+		Counter     string `json:"counter"`
+		CreateDate  string `json:"create_date"`
+		ConfirmDate string `json:"confirm_date"`
+		Info        struct {
+			QltyCountryRoad string `json:"qltyCountryRoad"`
+			Confirmed       int    `json:"confirmed"`
+			Gesperrt        int    `json:"gesperrt"`
+			Quality         int    `json:"quality"`
+			Label           string `json:"label"`
+			Tags            []any  `json:"tags"`
+			AlertType       int    `json:"alert_type"`
+			Precheck        string `json:"precheck"`
+			Desc            string `json:"desc"`
+			Fixed           int    `json:"fixed"`
+			Reason          string `json:"reason"`
+			Length          int    `json:"length"`
+			Duration        string `json:"duration"`
+			LatEnd          string `json:"lat_end"`
+			LngEnd          string `json:"lng_end"`
+		} `json:"info,omitempty"`
+		Polyline string `json:"polyline"`
+		Style    int    `json:"style"`
+	} `json:"pois"`
+	Grid  []any `json:"grid"`
+	Infos []any `json:"infos"`
+}
+
+// Atudo is the blitzer.de pois.php API
+type Atudo struct {
+	Client http.Client
+	URL    string
+}
+
+func NewAtudo() *Atudo {
+	return &Atudo{
+		Client: http.Client{
+			Timeout: time.Second * 15,
+		},
+		URL: AtudoURL,
+	}
+}
+
+func (a *Atudo) GetBlitzers(boxStart [2]float64, boxEnd [2]float64) ([]Blitzer, error) {
+	url := fmt.Sprintf(a.URL, boxStart[0], boxStart[1], boxEnd[0], boxEnd[1])
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("blitzer: %s returned %s", url, resp.Status)
+	}
+
+	response := Decode(resp)
+	if response == nil {
+		return nil, ErrDecode
+	}
+	return Normalize(*response), nil
+}
+
+// Decodes http response
+func Decode(resp *http.Response) *BlitzerDEResponse {
+	decoder := json.NewDecoder(resp.Body)
+	var t BlitzerDEResponse
+	err := decoder.Decode(&t)
+
+	// Print response:
+	// bodyBytes, _ := io.ReadAll(resp.Body)
+	// fmt.Println(string(bodyBytes))
+	// err := json.Unmarshal(bodyBytes, &t)
+
+	if err != nil {
+		print(err)
+		return nil
+	}
+	defer resp.Body.Close()
+	return &t
+}
+
+// Returns array of blitzers from response
+func GetBlitzer(blitzers BlitzerDEResponse, currPos [2]float64) []Blitzer {
+	a := Normalize(blitzers)
+	SetDistances(a, currPos)
+	return a
+}
+
+// Converts the atudo POIs into blitzers, POIs without Vmax are skipped unless their type warns anyway
+func Normalize(blitzers BlitzerDEResponse) []Blitzer {
+	a := []Blitzer{}
+	for _, blitzer := range blitzers.Pois {
+		kind := ParseType(blitzer.Type)
+		if blitzer.Vmax != "" || (kind != TypeUnknown && kind.Policy().Warn) {
+			lat, _ := strconv.ParseFloat(blitzer.Lat, 64)
+			lng, _ := strconv.ParseFloat(blitzer.Lng, 64)
+			vmax, _ := strconv.ParseInt(blitzer.Vmax, 0, 32)
+			b := Blitzer{
+				ID:     blitzer.ID,
+				Pos:    [2]float64{lat, lng},
+				Vmax:   int32(vmax),
+				City:   blitzer.Address.City,
+				Street: blitzer.Address.Street,
+				Fixed:  blitzer.Info.Fixed == 1,
+				Type:   kind,
+			}
+			// POIs with an end point (e.g. section control) are only valid from start to end
+			latEnd, err1 := strconv.ParseFloat(blitzer.Info.LatEnd, 64)
+			lngEnd, err2 := strconv.ParseFloat(blitzer.Info.LngEnd, 64)
+			if err1 == nil && err2 == nil {
+				b.End = [2]float64{latEnd, lngEnd}
+				b.Direction = GetBearing(b.Pos, b.End)
+				b.Directed = true
+				// length is given in metres
+				b.Length = float64(blitzer.Info.Length) / 1000
+				if b.Length == 0 {
+					b.Length = GetDist(b.Pos, b.End)
+				}
+			}
+			// the road bends, so direction and length follow the polyline where there is one
+			if path := DecodePolyline(blitzer.Polyline); len(path) >= 2 {
+				b.Path = path
+				b.Direction = GetBearing(path[0], path[1])
+				b.Directed = true
+				b.Length = GetPathLength(path)
+			}
+			b.Counter, _ = strconv.Atoi(blitzer.Counter)
+			b.Confirmed = blitzer.Info.Confirmed == 1
+			b.CreateDate = parseDate(blitzer.CreateDate)
+			b.ConfirmDate = parseDate(blitzer.ConfirmDate)
+			a = append(a, b)
+		}
+	}
+	return a
+}
+
+// Returns the German time zone, loading it reads the zoneinfo so it only happens once
+var germanLocation = sync.OnceValue(func() *time.Location {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		return time.Local
+	}
+	return location
+})
+
+// Parses atudo dates like "2025-01-13 10:10:14", they are local German time
+func parseDate(date string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", date, germanLocation())
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Decodes an encoded polyline (Google format, precision 5) into points
+func DecodePolyline(encoded string) [][2]float64 {
+	points := [][2]float64{}
+	lat, lng := 0, 0
+	for i := 0; i < len(encoded); {
+		var deltas [2]int
+		for j := range deltas {
+			shift, result := 0, 0
+			for i < len(encoded) {
+				b := int(encoded[i]) - 63
+				i++
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[j] = ^(result >> 1)
+			} else {
+				deltas[j] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lng += deltas[1]
+		points = append(points, [2]float64{float64(lat) / 1e5, float64(lng) / 1e5})
+	}
+	return points
+}
+
+// Returns the length of a path in km
+func GetPathLength(path [][2]float64) float64 {
+	length := 0.0
+	for i := 1; i < len(path); i++ {
+		length += GetDist(path[i-1], path[i])
+	}
+	return length
+}
@@ -1,61 +1,33 @@
 package blitzer
 
 import (
-	"encoding/json"
 	"math"
-	"net/http"
-	"strconv"
+	"time"
 )
 
-type BlitzerDEResponse struct {
-	Pois []struct {
-		ID      string `json:"id"`
-		Lat     string `json:"lat"`
-		Lng     string `json:"lng"`
-		Address struct {
-			Country      string `json:"country"`
-			State        string `json:"state"`
-			ZipCode      string `json:"zip_code"`
-			City         string `json:"city"`
-			CityDistrict string `json:"city_district"`
-			Street       string `json:"street"`
-		} `json:"address"`
-		Content     string `json:"content"`
-		Backend     string `json:"backend"`
-		Type        string `json:"type"`
-		Vmax        string `json:"vmax"`
-		Counter     string `json:"counter"`
-		CreateDate  string `json:"create_date"`
-		ConfirmDate string `json:"confirm_date"`
-		Info        struct {
-			QltyCountryRoad string `json:"qltyCountryRoad"`
-			Confirmed       int    `json:"confirmed"`
-			Gesperrt        int    `json:"gesperrt"`
-			Quality         int    `json:"quality"`
-			Label           string `json:"label"`
-			Tags            []any  `json:"tags"`
-			AlertType       int    `json:"alert_type"`
-			Precheck        string `json:"precheck"`
-			Desc            string `json:"desc"`
-			Fixed           int    `json:"fixed"`
-			Reason          string `json:"reason"`
-			Length          int    `json:"length"`
-			Duration        string `json:"duration"`
-			LatEnd          string `json:"lat_end"`
-			LngEnd          string `json:"lng_end"`
-		} `json:"info,omitempty"`
-		Polyline string `json:"polyline"`
-		Style    int    `json:"style"`
-	} `json:"pois"`
-	Grid  []any `json:"grid"`
-	Infos []any `json:"infos"`
-}
-
 type Blitzer struct {
+	ID       string
+	Pos      [2]float64
 	Vmax     int32
 	City     string
 	Street   string
 	Distance float64
+	Fixed    bool
+	Type     Type
+	// Direction of the traffic the blitzer measures, only known if Directed
+	Direction float64
+	Directed  bool
+	// End point, length in km and road geometry of a section control, zero otherwise
+	End    [2]float64
+	Length float64
+	Path   [][2]float64
+	// Distance in km the warning starts at, the policy distance scaled to the lookahead by FilterAlert, never more than the lookahead
+	AlertDistance float64
+	// Reports of mobile blitzers
+	CreateDate  time.Time
+	ConfirmDate time.Time
+	Counter     int
+	Confirmed   bool
 }
 
 type Point struct {
@@ -64,39 +36,6 @@ type Point struct {
 	z float64
 }
 
-// Decodes http response
-func Decode(resp *http.Response) *BlitzerDEResponse {
-	decoder := json.NewDecoder(resp.Body)
-	var t BlitzerDEResponse
-	err := decoder.Decode(&t)
-
-	// Print response:
-	// bodyBytes, _ := io.ReadAll(resp.Body)
-	// fmt.Println(string(bodyBytes))
-	// err := json.Unmarshal(bodyBytes, &t)
-
-	if err != nil {
-		print(err)
-		return nil
-	}
-	defer resp.Body.Close()
-	return &t
-}
-
-// Returns array of blitzers from response
-func GetBlitzer(blitzers BlitzerDEResponse, currPos [2]float64) []Blitzer {
-	a := []Blitzer{}
-	for _, blitzer := range blitzers.Pois {
-		if blitzer.Vmax != "" {
-			lat, _ := strconv.ParseFloat(blitzer.Lat, 64)
-			lng, _ := strconv.ParseFloat(blitzer.Lng, 64)
-			vmax, _ := strconv.ParseInt(blitzer.Vmax, 0, 32)
-			a = append(a, Blitzer{int32(vmax), blitzer.Address.City, blitzer.Address.Street, GetDist(currPos, [2]float64{lat, lng})})
-		}
-	}
-	return a
-}
-
 // Returns distance between two points
 func GetDist(start [2]float64, end [2]float64) float64 {
 	R := 6371.0
@@ -117,11 +56,9 @@ func GetDist(start [2]float64, end [2]float64) float64 {
 	return distance
 }
 
-// Returns scan box from 4 points
+// Returns scan box from 4 points, L1 km ahead of currPos and L2 km to each side
 // SPECIAL THANKS TO TIM SIEFKEN (I588350)
-func GetScanBox(lastPos [2]float64, currPos [2]float64) [4][2]float64 {
-	L1 := 1.0
-	L2 := 0.4
+func GetScanBox(lastPos [2]float64, currPos [2]float64, L1 float64, L2 float64) [4][2]float64 {
 	p0 := Get3DPos(lastPos)
 	p1 := Get3DPos(currPos)
 
@@ -186,7 +123,6 @@ func Get2DPos(point Point) [2]float64 {
 
 // Returns closest blitzer from an array of blitzers
 func GetClosestBlitzer(blitzers []Blitzer) Blitzer {
-	// MAN KANN NOCH INFO CASEN UND DANN FESTE, BEIDSEITIG RAUSFILTERN
 	lowest := blitzers[0]
 	for _, blitzer := range blitzers[1:] {
 		if blitzer.Distance < lowest.Distance {
@@ -195,3 +131,27 @@ func GetClosestBlitzer(blitzers []Blitzer) Blitzer {
 	}
 	return lowest
 }
+
+// Returns bearing from start to end in degrees, 0 = north
+func GetBearing(start [2]float64, end [2]float64) float64 {
+	lat1 := start[0] * math.Pi / 180
+	lat2 := end[0] * math.Pi / 180
+	dlon := (end[1] - start[1]) * math.Pi / 180
+
+	y := math.Sin(dlon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dlon)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// Returns the point distance km away from start in direction bearing
+func GetDestination(start [2]float64, bearing float64, distance float64) [2]float64 {
+	R := 6371.0
+	lat1 := start[0] * math.Pi / 180
+	lon1 := start[1] * math.Pi / 180
+	brng := bearing * math.Pi / 180
+	d := distance / R
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brng))
+	lon2 := lon1 + math.Atan2(math.Sin(brng)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+	return [2]float64{lat2 * 180 / math.Pi, lon2 * 180 / math.Pi}
+}
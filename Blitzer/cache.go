@@ -0,0 +1,191 @@
+package blitzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Zoom level of the cache tiles, ~1.6 km wide in southern Germany
+const TileZoom = 14
+
+// Tile is a slippy map tile (z/x/y)
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+type tileEntry struct {
+	Fetched  time.Time
+	Blitzers []Blitzer
+}
+
+// Cache answers from tiles fetched earlier and only asks the provider for missing or expired tiles
+type Cache struct {
+	Provider Provider
+	// Directory the tiles are persisted to, "" keeps them in memory only
+	Dir       string
+	TTLFixed  time.Duration
+	TTLMobile time.Duration
+
+	mu    sync.Mutex
+	tiles map[Tile]tileEntry
+}
+
+func NewCache(provider Provider, dir string) *Cache {
+	return &Cache{
+		Provider:  provider,
+		Dir:       dir,
+		TTLFixed:  7 * 24 * time.Hour,
+		TTLMobile: 2 * time.Minute,
+		tiles:     map[Tile]tileEntry{},
+	}
+}
+
+// Returns the tile containing pos
+func GetTile(pos [2]float64, zoom int) Tile {
+	n := math.Exp2(float64(zoom))
+	lat := pos[0] * math.Pi / 180
+	x := int(math.Floor((pos[1] + 180) / 360 * n))
+	y := int(math.Floor((1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * n))
+	return Tile{zoom, x, y}
+}
+
+// Returns bounding box of the tile
+func (t Tile) Bounds() ([2]float64, [2]float64) {
+	n := math.Exp2(float64(t.Z))
+	lng := func(x int) float64 { return float64(x)/n*360 - 180 }
+	lat := func(y int) float64 { return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi }
+	// y grows to the south
+	return [2]float64{lat(t.Y + 1), lng(t.X)}, [2]float64{lat(t.Y), lng(t.X + 1)}
+}
+
+// Returns all tiles covering the bounding box
+func GetTiles(boxStart [2]float64, boxEnd [2]float64, zoom int) []Tile {
+	from := GetTile([2]float64{boxEnd[0], boxStart[1]}, zoom)
+	to := GetTile([2]float64{boxStart[0], boxEnd[1]}, zoom)
+	tiles := []Tile{}
+	for x := from.X; x <= to.X; x++ {
+		for y := from.Y; y <= to.Y; y++ {
+			tiles = append(tiles, Tile{zoom, x, y})
+		}
+	}
+	return tiles
+}
+
+// Returns the blitzers of all tiles covering the box. A tile that can't be fetched is left out,
+// the error is only returned if no tile is available at all.
+func (c *Cache) GetBlitzers(boxStart [2]float64, boxEnd [2]float64) ([]Blitzer, error) {
+	a := []Blitzer{}
+	var lastErr error
+	available := 0
+	for _, tile := range GetTiles(boxStart, boxEnd, TileZoom) {
+		blitzers, err := c.getTile(tile)
+		if err != nil {
+			print("Error fetching tile: ", err.Error(), "\n")
+			lastErr = err
+			continue
+		}
+		available++
+		for _, blitzer := range blitzers {
+			if InBox(blitzer.Pos, boxStart, boxEnd) {
+				a = append(a, blitzer)
+			}
+		}
+	}
+	if available == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return a, nil
+}
+
+// Returns blitzers of a tile, fetches it if it is missing or expired.
+// If fetching fails an expired tile is still better than nothing, as long as its fixed blitzers are recent.
+// Its mobile blitzers are gone after TTLMobile though, they would only warn about something long gone.
+func (c *Cache) getTile(tile Tile) ([]Blitzer, error) {
+	entry, ok := c.lookup(tile)
+	if ok && !c.expired(entry, time.Now()) {
+		return entry.Blitzers, nil
+	}
+
+	boxStart, boxEnd := tile.Bounds()
+	blitzers, err := c.Provider.GetBlitzers(boxStart, boxEnd)
+	if err != nil {
+		if ok && time.Since(entry.Fetched) <= c.TTLFixed {
+			fixed := []Blitzer{}
+			for _, blitzer := range entry.Blitzers {
+				if blitzer.Fixed {
+					fixed = append(fixed, blitzer)
+				}
+			}
+			return fixed, nil
+		}
+		return nil, err
+	}
+
+	entry = tileEntry{Fetched: time.Now(), Blitzers: blitzers}
+	c.mu.Lock()
+	c.tiles[tile] = entry
+	c.mu.Unlock()
+	if c.Dir != "" {
+		if err := c.save(tile, entry); err != nil {
+			print("Error saving tile: ", err.Error(), "\n")
+		}
+	}
+	return blitzers, nil
+}
+
+// Returns the tile from memory or disk
+func (c *Cache) lookup(tile Tile) (tileEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.tiles[tile]; ok {
+		return entry, true
+	}
+	if c.Dir == "" {
+		return tileEntry{}, false
+	}
+
+	data, err := os.ReadFile(c.tilePath(tile))
+	if err != nil {
+		return tileEntry{}, false
+	}
+	var entry tileEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return tileEntry{}, false
+	}
+	c.tiles[tile] = entry
+	return entry, true
+}
+
+// Any tile can get a mobile blitzer anytime, also one with only fixed blitzers, so every tile is fetched
+// again after TTLMobile. TTLFixed only limits how long a tile is used while the provider can't be reached.
+func (c *Cache) expired(entry tileEntry, now time.Time) bool {
+	return now.Sub(entry.Fetched) > c.TTLMobile
+}
+
+func (c *Cache) tilePath(tile Tile) string {
+	return filepath.Join(c.Dir, fmt.Sprint(tile.Z), fmt.Sprint(tile.X), fmt.Sprintf("%d.json", tile.Y))
+}
+
+func (c *Cache) save(tile Tile, entry tileEntry) error {
+	path := c.tilePath(tile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write and rename, so pulling the power doesn't leave half a tile
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
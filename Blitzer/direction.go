@@ -0,0 +1,39 @@
+package blitzer
+
+import (
+	"math"
+)
+
+const (
+	// Blitzers further than this off our heading are not in front of us
+	AheadAngle = 60.0
+	// Blitzers measuring traffic further than this off our heading are for the other lane
+	DirectionTolerance = 45.0
+	// Right next to a blitzer the bearing jumps around, keep it until we passed
+	passDistance = 0.02
+)
+
+// Returns the smallest angle between two bearings, 0 to 180 degrees
+func GetAngleDiff(a float64, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	if diff > 180 {
+		return 360 - diff
+	}
+	return diff
+}
+
+// Returns only blitzers ahead of currPos that can flash us driving in direction heading.
+// Distance must be set.
+func FilterAhead(blitzers []Blitzer, currPos [2]float64, heading float64) []Blitzer {
+	a := []Blitzer{}
+	for _, blitzer := range blitzers {
+		if blitzer.Distance > passDistance && GetAngleDiff(GetBearing(currPos, blitzer.Pos), heading) > AheadAngle {
+			continue
+		}
+		if blitzer.Directed && GetAngleDiff(blitzer.Direction, heading) > DirectionTolerance {
+			continue
+		}
+		a = append(a, blitzer)
+	}
+	return a
+}
@@ -0,0 +1,73 @@
+package blitzer
+
+import (
+	"time"
+)
+
+// Returns time since the blitzer was reported or last confirmed, 0 if we don't know
+func (b Blitzer) GetAge(now time.Time) time.Duration {
+	last := b.ConfirmDate
+	if last.IsZero() {
+		last = b.CreateDate
+	}
+	if last.IsZero() {
+		return 0
+	}
+	return now.Sub(last)
+}
+
+// Returns 1 to 3, how sure we are the blitzer is really there
+func (b Blitzer) GetConfidence() int {
+	if b.Fixed {
+		return 3
+	}
+	confidence := 1
+	if b.Counter >= 3 {
+		confidence++
+	}
+	if b.Confirmed {
+		confidence++
+	}
+	return confidence
+}
+
+// Returns blitzers without mobile reports older than maxAge, 0 keeps all
+func FilterExpired(blitzers []Blitzer, maxAge time.Duration, now time.Time) []Blitzer {
+	a := []Blitzer{}
+	for _, blitzer := range blitzers {
+		if !blitzer.Fixed && maxAge > 0 && blitzer.GetAge(now) > maxAge {
+			continue
+		}
+		a = append(a, blitzer)
+	}
+	return a
+}
+
+// Reports of the same mobile blitzer are often scattered over this many km along the road
+const ConfirmationBand = 0.15
+
+// Returns the blitzer to warn about: of all within band km of the closest one the best confirmed wins,
+// on equal confirmation the more often reported one, then the closer one
+func GetBestBlitzer(blitzers []Blitzer, band float64) Blitzer {
+	closest := GetClosestBlitzer(blitzers)
+	best := closest
+	for _, blitzer := range blitzers {
+		if blitzer.Distance > closest.Distance+band {
+			continue
+		}
+		switch {
+		case blitzer.GetConfidence() != best.GetConfidence():
+			if blitzer.GetConfidence() < best.GetConfidence() {
+				continue
+			}
+		case blitzer.Counter != best.Counter:
+			if blitzer.Counter < best.Counter {
+				continue
+			}
+		case blitzer.Distance >= best.Distance:
+			continue
+		}
+		best = blitzer
+	}
+	return best
+}
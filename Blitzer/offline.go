@@ -0,0 +1,220 @@
+package blitzer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Grid cell size in degrees, ~5 km in Germany
+const cellSize = 0.05
+
+// Offline is a local database of fixed blitzers, indexed by a lat/lng grid
+type Offline struct {
+	mu    sync.RWMutex
+	cells map[[2]int][]Blitzer
+	ids   map[string]bool
+}
+
+func NewOffline() *Offline {
+	return &Offline{
+		cells: map[[2]int][]Blitzer{},
+		ids:   map[string]bool{},
+	}
+}
+
+// Loads a database from a .csv, .geojson or atudo .json file
+func LoadOffline(path string) (*Offline, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	o := NewOffline()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		err = o.ImportCSV(file)
+	case ".geojson":
+		err = o.ImportGeoJSON(file)
+	case ".json":
+		err = o.ImportAtudo(file)
+	default:
+		err = fmt.Errorf("blitzer: unknown database format %q", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func getCell(pos [2]float64) [2]int {
+	return [2]int{int(math.Floor(pos[0] / cellSize)), int(math.Floor(pos[1] / cellSize))}
+}
+
+// Adds blitzers, blitzers with an already known ID are skipped
+func (o *Offline) Add(blitzers ...Blitzer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, blitzer := range blitzers {
+		if blitzer.ID != "" {
+			if o.ids[blitzer.ID] {
+				continue
+			}
+			o.ids[blitzer.ID] = true
+		}
+		cell := getCell(blitzer.Pos)
+		o.cells[cell] = append(o.cells[cell], blitzer)
+	}
+}
+
+func (o *Offline) GetBlitzers(boxStart [2]float64, boxEnd [2]float64) ([]Blitzer, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	from := getCell(boxStart)
+	to := getCell(boxEnd)
+	a := []Blitzer{}
+	for lat := from[0]; lat <= to[0]; lat++ {
+		for lng := from[1]; lng <= to[1]; lng++ {
+			for _, blitzer := range o.cells[[2]int{lat, lng}] {
+				if InBox(blitzer.Pos, boxStart, boxEnd) {
+					a = append(a, blitzer)
+				}
+			}
+		}
+	}
+	return a, nil
+}
+
+// Returns number of blitzers in the database
+func (o *Offline) Len() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	count := 0
+	for _, cell := range o.cells {
+		count += len(cell)
+	}
+	return count
+}
+
+// Imports lines of lat,lng,vmax[,city,street[,id]], a header line is skipped
+func (o *Offline) ImportCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	for i, record := range records {
+		if len(record) < 3 {
+			return fmt.Errorf("blitzer: csv line %d: want lat,lng,vmax", i+1)
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return fmt.Errorf("blitzer: csv line %d: %w", i+1, err)
+		}
+		lng, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			return fmt.Errorf("blitzer: csv line %d: %w", i+1, err)
+		}
+		vmax, err := strconv.ParseInt(strings.TrimSpace(record[2]), 10, 32)
+		if err != nil {
+			return fmt.Errorf("blitzer: csv line %d: %w", i+1, err)
+		}
+		blitzer := Blitzer{Pos: [2]float64{lat, lng}, Vmax: int32(vmax), Fixed: true}
+		if len(record) >= 5 {
+			blitzer.City = record[3]
+			blitzer.Street = record[4]
+		}
+		if len(record) >= 6 {
+			blitzer.ID = record[5]
+		}
+		o.Add(blitzer)
+	}
+	return nil
+}
+
+type geoJSON struct {
+	Features []struct {
+		ID       any `json:"id"`
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+// Imports Point features, vmax is read from the vmax or maxspeed property
+func (o *Offline) ImportGeoJSON(r io.Reader) error {
+	var t geoJSON
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return err
+	}
+	for _, feature := range t.Features {
+		if feature.Geometry.Type != "Point" || len(feature.Geometry.Coordinates) < 2 {
+			continue
+		}
+		vmax := getProperty(feature.Properties, "vmax", "maxspeed")
+		limit, err := strconv.ParseInt(vmax, 10, 32)
+		if err != nil {
+			continue
+		}
+		blitzer := Blitzer{
+			// GeoJSON is lng, lat
+			Pos:    [2]float64{feature.Geometry.Coordinates[1], feature.Geometry.Coordinates[0]},
+			Vmax:   int32(limit),
+			City:   getProperty(feature.Properties, "city", "addr:city"),
+			Street: getProperty(feature.Properties, "street", "addr:street"),
+			Fixed:  true,
+		}
+		if feature.ID != nil {
+			blitzer.ID = fmt.Sprint(feature.ID)
+		}
+		o.Add(blitzer)
+	}
+	return nil
+}
+
+// Returns the first of keys that is set as string
+func getProperty(properties map[string]any, keys ...string) string {
+	for _, key := range keys {
+		switch value := properties[key].(type) {
+		case string:
+			return value
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// Imports a saved pois.php response, only fixed blitzers are kept
+func (o *Offline) ImportAtudo(r io.Reader) error {
+	var t BlitzerDEResponse
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return err
+	}
+	for _, blitzer := range Normalize(t) {
+		if blitzer.Fixed {
+			o.Add(blitzer)
+		}
+	}
+	return nil
+}
+
+// Returns whether pos lies inside the bounding box
+func InBox(pos [2]float64, boxStart [2]float64, boxEnd [2]float64) bool {
+	return pos[0] >= boxStart[0] && pos[0] <= boxEnd[0] && pos[1] >= boxStart[1] && pos[1] <= boxEnd[1]
+}
@@ -0,0 +1,82 @@
+package blitzer
+
+import (
+	"time"
+)
+
+// Prefetcher loads the tiles of a corridor ahead of us into the cache,
+// so a connectivity drop right before a blitzer doesn't hide it
+type Prefetcher struct {
+	Cache *Cache
+	// How far ahead in driving time the corridor reaches
+	Horizon time.Duration
+	// Corridor length limits in km
+	MinDistance float64
+	MaxDistance float64
+	// Half width of the corridor in km
+	Width float64
+}
+
+func NewPrefetcher(cache *Cache) *Prefetcher {
+	return &Prefetcher{
+		Cache:       cache,
+		Horizon:     3 * time.Minute,
+		MinDistance: 3.0,
+		MaxDistance: 10.0,
+		Width:       0.4,
+	}
+}
+
+// Returns corridor length in km for speed in km/h
+func (p *Prefetcher) GetCorridorLength(speed float64) float64 {
+	length := speed * p.Horizon.Hours()
+	if length < p.MinDistance {
+		return p.MinDistance
+	}
+	if length > p.MaxDistance {
+		return p.MaxDistance
+	}
+	return length
+}
+
+// Returns the tiles of the corridor starting at currPos in direction heading
+func (p *Prefetcher) GetCorridor(currPos [2]float64, heading float64, speed float64) []Tile {
+	length := p.GetCorridorLength(speed)
+	seen := map[Tile]bool{}
+	tiles := []Tile{}
+
+	// Walk the corridor in 1 km pieces, each piece is covered by its bounding box
+	for start := 0.0; start < length; start += 1.0 {
+		end := start + 1.0
+		if end > length {
+			end = length
+		}
+		from := GetDestination(currPos, heading, start)
+		to := GetDestination(currPos, heading, end)
+		points := [4][2]float64{
+			GetDestination(from, heading-90, p.Width),
+			GetDestination(from, heading+90, p.Width),
+			GetDestination(to, heading-90, p.Width),
+			GetDestination(to, heading+90, p.Width),
+		}
+		boxStart, boxEnd := GetBoundingBox(points)
+		for _, tile := range GetTiles(boxStart, boxEnd, TileZoom) {
+			if !seen[tile] {
+				seen[tile] = true
+				tiles = append(tiles, tile)
+			}
+		}
+	}
+	return tiles
+}
+
+// Fetches all missing or expired tiles of the corridor, returns the first error but keeps going
+func (p *Prefetcher) Prefetch(currPos [2]float64, heading float64, speed float64) error {
+	var first error
+	for _, tile := range p.GetCorridor(currPos, heading, speed) {
+		if _, err := p.Cache.getTile(tile); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
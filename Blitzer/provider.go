@@ -0,0 +1,30 @@
+package blitzer
+
+// Provider is a source of blitzers, e.g. the atudo API, a local database or a test fake
+type Provider interface {
+	// Returns all blitzers inside the bounding box from GetBoundingBox, Distance is not set
+	GetBlitzers(boxStart [2]float64, boxEnd [2]float64) ([]Blitzer, error)
+}
+
+// Fallback asks its providers in order and returns the first answer without error,
+// e.g. the atudo API first and the offline database when there is no network
+type Fallback []Provider
+
+func (f Fallback) GetBlitzers(boxStart [2]float64, boxEnd [2]float64) ([]Blitzer, error) {
+	var err error
+	for _, provider := range f {
+		var blitzers []Blitzer
+		blitzers, err = provider.GetBlitzers(boxStart, boxEnd)
+		if err == nil {
+			return blitzers, nil
+		}
+	}
+	return nil, err
+}
+
+// Sets Distance of every blitzer relative to currPos
+func SetDistances(blitzers []Blitzer, currPos [2]float64) {
+	for i := range blitzers {
+		blitzers[i].Distance = GetDist(currPos, blitzers[i].Pos)
+	}
+}
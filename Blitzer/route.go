@@ -0,0 +1,28 @@
+package blitzer
+
+import (
+	"errors"
+	"math"
+)
+
+// Routers return this if there is no way to the destination within their search distance
+var ErrUnreachable = errors.New("blitzer: destination unreachable")
+
+// Router knows the driving distance between two points, e.g. the OSM road graph
+type Router interface {
+	// Returns the distance in km along the road network
+	GetRouteDist(from [2]float64, to [2]float64) (float64, error)
+}
+
+// Replaces the straight line Distance with the distance we will actually drive.
+// Unreachable blitzers are pushed to infinity, if the router doesn't know the road the straight line stays.
+func SetRoadDistances(blitzers []Blitzer, currPos [2]float64, router Router) {
+	for i := range blitzers {
+		distance, err := router.GetRouteDist(currPos, blitzers[i].Pos)
+		if errors.Is(err, ErrUnreachable) {
+			blitzers[i].Distance = math.Inf(1)
+		} else if err == nil {
+			blitzers[i].Distance = distance
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package blitzer
+
+// Scan box of a position without speed, as it always was
+const (
+	DefaultLookahead = 1.0
+	DefaultWidth     = 0.4
+)
+
+// Seconds of warning we want before reaching a blitzer
+const ScanLead = 45.0
+
+// Lookahead limits in km, town traffic still needs a few hundred metres, beyond 3 km it's not our road anymore
+const (
+	minLookahead = 0.5
+	maxLookahead = 3.0
+)
+
+// Returns lookahead L1 and half width L2 of the scan box in km for speed in km/h and the OSM highway class
+// of the road we are on ("" if unknown), so a blitzer shows up about ScanLead seconds ahead
+func GetScanSize(speed float64, highway string) (float64, float64) {
+	lookahead := DefaultLookahead
+	if speed > 0 {
+		lookahead = speed / 3600 * ScanLead
+	}
+	if lookahead < minLookahead {
+		lookahead = minLookahead
+	}
+	if lookahead > maxLookahead {
+		lookahead = maxLookahead
+	}
+
+	width := DefaultWidth
+	switch highway {
+	case "motorway", "motorway_link", "trunk", "trunk_link":
+		// Wide curves, but the other carriageway is close
+		width = 0.3
+	case "residential", "living_street", "service":
+		// Parallel streets are only a block away
+		width = 0.2
+	}
+	return lookahead, width
+}
@@ -0,0 +1,111 @@
+package blitzer
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// How close in km we have to pass the start or end point of a section control
+	sectionRadius = 0.05
+	// Give up if we drove this much further than the section is long, e.g. left the road
+	sectionSlack = 0.5
+)
+
+// SectionState is what the HUD shows while we are inside a section control
+type SectionState struct {
+	Active  bool
+	Vmax    int32
+	Average float64 // km/h
+	Driven  float64 // km
+	// Distance left to the end point in km
+	Remaining float64
+	Elapsed   time.Duration
+	TooFast   bool
+}
+
+// SectionTracker detects entering a section control and tracks our average speed until its end
+type SectionTracker struct {
+	section Blitzer
+	active  bool
+	entered time.Time
+	driven  float64
+	lastPos [2]float64
+	lastAt  time.Time
+}
+
+// Feeds the tracker with the blitzers around us and the current position, returns the state for the HUD.
+// Counting starts when we pass the start point and ends once we passed the end point.
+func (s *SectionTracker) Update(blitzers []Blitzer, currPos [2]float64, heading float64, at time.Time) SectionState {
+	if !s.active {
+		for _, blitzer := range blitzers {
+			if blitzer.Type != TypeSectionControl || !blitzer.Directed {
+				continue
+			}
+			if GetDist(currPos, blitzer.Pos) >= sectionRadius || GetAngleDiff(blitzer.Direction, heading) >= DirectionTolerance {
+				continue
+			}
+			along := GetAlong(blitzer.Pos, blitzer.Direction, currPos)
+			if along < 0 {
+				// still in front of the start
+				continue
+			}
+			s.section = blitzer
+			s.active = true
+			s.entered = at
+			s.driven = 0
+			// we passed the start since the last update, the time in between is split by distance
+			if lastAlong := GetAlong(blitzer.Pos, blitzer.Direction, s.lastPos); !s.lastAt.IsZero() && lastAlong < 0 {
+				fraction := -lastAlong / (along - lastAlong)
+				s.entered = s.lastAt.Add(time.Duration(fraction * float64(at.Sub(s.lastAt))))
+				s.driven = along
+			}
+			break
+		}
+		if !s.active {
+			s.lastPos = currPos
+			s.lastAt = at
+			return SectionState{}
+		}
+	} else {
+		s.driven += GetDist(s.lastPos, currPos)
+	}
+	s.lastPos = currPos
+	s.lastAt = at
+
+	passedEnd := GetDist(currPos, s.section.End) < sectionRadius && GetAlong(s.section.End, s.getExitBearing(), currPos) >= 0
+	if passedEnd || s.driven > s.section.Length+sectionSlack {
+		s.active = false
+		return SectionState{}
+	}
+
+	state := SectionState{
+		Active:    true,
+		Vmax:      s.section.Vmax,
+		Driven:    s.driven,
+		Remaining: s.section.Length - s.driven,
+		Elapsed:   at.Sub(s.entered),
+	}
+	if state.Remaining < 0 {
+		state.Remaining = GetDist(currPos, s.section.End)
+	}
+	if state.Elapsed > 0 {
+		state.Average = s.driven / state.Elapsed.Hours()
+	}
+	state.TooFast = s.section.Vmax > 0 && state.Average > float64(s.section.Vmax)
+	return state
+}
+
+// Returns the direction we drive through the end point, the last piece of the road if we know it
+func (s *SectionTracker) getExitBearing() float64 {
+	if path := s.section.Path; len(path) >= 2 {
+		return GetBearing(path[len(path)-2], path[len(path)-1])
+	}
+	return GetBearing(s.section.Pos, s.section.End)
+}
+
+// Returns how far pos is ahead of origin in direction bearing in km, negative behind it
+func GetAlong(origin [2]float64, bearing float64, pos [2]float64) float64 {
+	angle := (GetBearing(origin, pos) - bearing) * math.Pi / 180
+	return GetDist(origin, pos) * math.Cos(angle)
+}
@@ -0,0 +1,116 @@
+package blitzer
+
+import (
+	"strings"
+)
+
+// Type of a blitzer, parsed from the atudo type code
+type Type int
+
+const (
+	TypeUnknown Type = iota
+	TypeSpeed
+	TypeRedLight
+	TypeRedLightSpeed
+	TypeSectionControl
+	TypeDistance
+	TypeMobile
+	TypeSemiStationary
+	TypeTrafficJamEnd
+	TypeHazard
+)
+
+// AlertPolicy tells the HUD how to warn about a blitzer type
+type AlertPolicy struct {
+	// Whether to warn at all
+	Warn bool
+	// Distance in km from which on we warn
+	Distance float64
+}
+
+// Returns the type for an atudo type code, e.g. "6" is a distance measurement
+func ParseType(code string) Type {
+	switch strings.TrimSpace(code) {
+	case "0", "1", "5":
+		// 5 is a tunnel blitzer, for us just speed
+		return TypeSpeed
+	case "2":
+		return TypeRedLight
+	case "3":
+		return TypeRedLightSpeed
+	case "4":
+		return TypeSectionControl
+	case "6":
+		return TypeDistance
+	case "ts":
+		return TypeSemiStationary
+	case "20":
+		return TypeTrafficJamEnd
+	case "21", "22", "23", "24", "25", "26", "29", "vwd", "traffic":
+		// accident, construction, obstacle, slippery road, bad sight, ...
+		return TypeHazard
+	}
+	if len(code) == 3 && strings.HasPrefix(code, "1") {
+		// 101 to 117 are reported mobile blitzers
+		return TypeMobile
+	}
+	return TypeUnknown
+}
+
+func (t Type) String() string {
+	switch t {
+	case TypeSpeed:
+		return "speed"
+	case TypeRedLight:
+		return "red light"
+	case TypeRedLightSpeed:
+		return "red light and speed"
+	case TypeSectionControl:
+		return "section control"
+	case TypeDistance:
+		return "distance"
+	case TypeMobile:
+		return "mobile"
+	case TypeSemiStationary:
+		return "semi stationary"
+	case TypeTrafficJamEnd:
+		return "traffic jam end"
+	case TypeHazard:
+		return "hazard"
+	}
+	return "unknown"
+}
+
+// Returns how the HUD should warn about this type
+func (t Type) Policy() AlertPolicy {
+	switch t {
+	case TypeRedLight:
+		// only matters right at the crossing
+		return AlertPolicy{Warn: true, Distance: 0.3}
+	case TypeRedLightSpeed:
+		return AlertPolicy{Warn: true, Distance: 0.5}
+	case TypeTrafficJamEnd:
+		// braking from Autobahn speed needs more room
+		return AlertPolicy{Warn: true, Distance: 2.0}
+	case TypeHazard:
+		return AlertPolicy{Warn: false, Distance: 0.5}
+	}
+	return AlertPolicy{Warn: true, Distance: 1.0}
+}
+
+// Returns only blitzers whose type asks for a warning at their distance. Distance must be set.
+// The policy distances are meant for DefaultLookahead and grow and shrink with lookahead, so the warning
+// comes about as many seconds ahead at any speed. Sets AlertDistance to the scaled distance, capped at lookahead
+// because nothing farther away is in the scan box.
+func FilterAlert(blitzers []Blitzer, lookahead float64) []Blitzer {
+	scale := lookahead / DefaultLookahead
+	a := []Blitzer{}
+	for _, blitzer := range blitzers {
+		policy := blitzer.Type.Policy()
+		blitzer.AlertDistance = min(policy.Distance*scale, lookahead)
+		if policy.Warn && blitzer.Distance <= blitzer.AlertDistance {
+			a = append(a, blitzer)
+		}
+	}
+	return a
+}
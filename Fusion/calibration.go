@@ -0,0 +1,150 @@
+package fusion
+
+import (
+	GPS "FesterBlitzer/GPS"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Speedometers may show more but never less than the true speed (ECE-R 39), so the factor stays in a sane range
+const (
+	minFactor = 0.85
+	maxFactor = 1.05
+)
+
+type calibrationState struct {
+	Factor float64 `json:"factor"`
+	// Weighted sums of the least squares fit gps = factor * obd
+	SumXY   float64   `json:"sumXY"`
+	SumXX   float64   `json:"sumXX"`
+	Samples int       `json:"samples"`
+	Updated time.Time `json:"updated"`
+}
+
+// Calibration learns how much the OBD speed differs from the GPS ground speed
+type Calibration struct {
+	Path string
+	// GPS speed is noisy when slow, only calibrate above this in km/h
+	MinSpeed float64
+	// Largest change of the GPS speed between two fixes in km/h, while accelerating OBD and GPS don't line up
+	MaxChange float64
+	// Weight of older samples, 0.995 forgets over a few minutes of driving
+	Decay float64
+	// Samples needed before the factor is used
+	MinSamples int
+	// Only corrects without learning, for simulated OBD data
+	Frozen bool
+
+	mu       sync.Mutex
+	state    calibrationState
+	lastFix  time.Time
+	lastSeen time.Time
+	lastGPS  float64
+	unsaved  int
+}
+
+// Loads a saved calibration, starts from 1 if there is none
+func LoadCalibration(path string) *Calibration {
+	c := &Calibration{
+		Path:       path,
+		MinSpeed:   30,
+		MaxChange:  2,
+		Decay:      0.995,
+		MinSamples: 60,
+		state:      calibrationState{Factor: 1},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &c.state)
+	}
+	if err != nil || c.state.Factor < minFactor || c.state.Factor > maxFactor {
+		print("Ignoring speed calibration ", path, "\n")
+		c.state = calibrationState{Factor: 1}
+	}
+	return c
+}
+
+// Adds an OBD speed reading in km/h, only the first reading after each new GPS fix counts.
+// Returns whether the sample was used.
+func (c *Calibration) Add(obd float64, pos GPS.Position, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Frozen || pos.Time.Equal(c.lastFix) {
+		return false
+	}
+	// GPS timestamps can be from a replay, so freshness is measured with our own clock
+	previous := c.lastGPS
+	stale := now.Sub(c.lastSeen) > 1500*time.Millisecond
+	c.lastFix = pos.Time
+	c.lastSeen = now
+	c.lastGPS = pos.Speed
+
+	if stale || pos.Quality == 0 || pos.Speed < c.MinSpeed || obd < c.MinSpeed {
+		return false
+	}
+	if math.Abs(pos.Speed-previous) > c.MaxChange {
+		return false
+	}
+
+	c.state.SumXY = c.state.SumXY*c.Decay + obd*pos.Speed
+	c.state.SumXX = c.state.SumXX*c.Decay + obd*obd
+	c.state.Samples++
+	c.state.Updated = now
+	if c.state.Samples >= c.MinSamples {
+		c.state.Factor = math.Max(minFactor, math.Min(maxFactor, c.state.SumXY/c.state.SumXX))
+	}
+
+	c.unsaved++
+	if c.unsaved >= 100 {
+		if err := c.save(); err != nil {
+			print("Could not save speed calibration: ", err.Error(), "\n")
+		}
+	}
+	return true
+}
+
+// Returns the correction factor, 1 until enough samples were collected
+func (c *Calibration) Factor() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Factor
+}
+
+// Returns the OBD speed corrected to ground speed
+func (c *Calibration) Correct(obd float64) float64 {
+	return obd * c.Factor()
+}
+
+func (c *Calibration) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *Calibration) save() error {
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	// Write and rename, so pulling the power doesn't leave half a file
+	tmp := c.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	c.unsaved = 0
+	return os.Rename(tmp, c.Path)
+}
@@ -0,0 +1,354 @@
+package fusion
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	GPS "FesterBlitzer/GPS"
+	"math"
+	"sync"
+	"time"
+)
+
+// Fuser is a position provider that also takes the OBD speed, DeadReckoning and Filter both are one
+type Fuser interface {
+	GPS.Provider
+	// Sets the current speed in km/h
+	SetSpeed(speed float64, at time.Time)
+}
+
+// Indices into the filter state: east and north in m from the reference point, heading in rad
+// clockwise from north, speed in m/s and yaw rate in rad/s
+const (
+	stateEast = iota
+	stateNorth
+	stateHeading
+	stateSpeed
+	stateYawRate
+	stateCount
+)
+
+type matrix [stateCount][stateCount]float64
+
+// Variance of a heading we know nothing about, in rad²
+const maxHeadingVariance = math.Pi * math.Pi
+
+// State is the fused estimate
+type State struct {
+	Pos     [2]float64
+	Heading float64 // degrees, 0 = north
+	Speed   float64 // km/h
+	YawRate float64 // degrees per second
+	// Covariance of east (m), north (m), heading (rad), speed (m/s) and yaw rate (rad/s)
+	Covariance [stateCount][stateCount]float64
+	Time       time.Time
+}
+
+// Returns the standard deviation of the position in km
+func (s State) PosError() float64 {
+	return math.Sqrt(s.Covariance[stateEast][stateEast]+s.Covariance[stateNorth][stateNorth]) / 1000
+}
+
+// Returns the standard deviation of the heading in degrees
+func (s State) HeadingError() float64 {
+	return math.Sqrt(s.Covariance[stateHeading][stateHeading]) * 180 / math.Pi
+}
+
+// Filter is an extended Kalman filter over position, heading, speed and yaw rate with a constant turn rate model.
+// GPS fixes, the OBD speed and an optional gyro correct it, in between it predicts. It is a GPS.Provider itself.
+type Filter struct {
+	GPS GPS.Provider
+	// Without a GPS fix for this long the estimate is given up
+	MaxLoss time.Duration
+	// Position error in km above which Position reports no fix
+	MaxError float64
+
+	// Process noise: acceleration in m/s², change of the yaw rate in rad/s²
+	AccelNoise float64
+	TurnNoise  float64
+	// Measurement noise: GPS speed and OBD speed in m/s, GPS course in rad, gyro in rad/s
+	GPSSpeedNoise float64
+	OBDSpeedNoise float64
+	CourseNoise   float64
+	YawRateNoise  float64
+
+	mu      sync.Mutex
+	ref     [2]float64
+	x       [stateCount]float64
+	p       matrix
+	at      time.Time
+	started bool
+	fix     GPS.Position
+	fixSeen time.Time
+}
+
+func NewFilter(gps GPS.Provider) *Filter {
+	return &Filter{
+		GPS:           gps,
+		MaxLoss:       time.Minute,
+		MaxError:      0.5,
+		AccelNoise:    3,
+		TurnNoise:     0.02,
+		GPSSpeedNoise: 0.5,
+		OBDSpeedNoise: 0.3,
+		CourseNoise:   5 * math.Pi / 180,
+		YawRateNoise:  0.02,
+	}
+}
+
+// Corrects the speed with an OBD reading in km/h
+func (f *Filter) SetSpeed(speed float64, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.started {
+		return
+	}
+	f.predict(at)
+	f.correct(stateSpeed, speed/3.6, f.OBDSpeedNoise*f.OBDSpeedNoise)
+}
+
+// Corrects the turn rate with a gyro reading in degrees per second, positive turns right
+func (f *Filter) SetYawRate(rate float64, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.started {
+		return
+	}
+	f.predict(at)
+	f.correct(stateYawRate, rate*math.Pi/180, f.YawRateNoise*f.YawRateNoise)
+}
+
+func (f *Filter) Position() (GPS.Position, bool) {
+	return f.PositionAt(time.Now())
+}
+
+// Returns the fused position as if it came from the receiver, LastPos lies behind us on the fused heading
+func (f *Filter) PositionAt(now time.Time) (GPS.Position, bool) {
+	state, ok := f.StateAt(now)
+	if !ok {
+		pos, _ := f.GPS.Position()
+		return pos, false
+	}
+	f.mu.Lock()
+	pos := f.fix
+	f.mu.Unlock()
+
+	pos.CurrPos = state.Pos
+	pos.LastPos = Blitzer.GetDestination(state.Pos, state.Heading+180, 0.02)
+	pos.Heading = state.Heading
+	pos.Speed = state.Speed
+	pos.Time = state.Time
+	return pos, true
+}
+
+// Feeds a new GPS fix into the filter if there is one and returns the estimate at now
+func (f *Filter) StateAt(now time.Time) (State, bool) {
+	pos, ok := f.GPS.Position()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// GPS timestamps can be from a replay, so time is measured with our own clock
+	if ok && (!f.started || !pos.Time.Equal(f.fix.Time)) {
+		f.addFix(pos, now)
+	}
+	if !f.started || now.Sub(f.fixSeen) > f.MaxLoss {
+		return State{}, false
+	}
+	f.predict(now)
+
+	state := State{
+		Pos:        f.toLatLng(f.x[stateEast], f.x[stateNorth]),
+		Heading:    math.Mod(f.x[stateHeading]*180/math.Pi+360, 360),
+		Speed:      f.x[stateSpeed] * 3.6,
+		YawRate:    f.x[stateYawRate] * 180 / math.Pi,
+		Covariance: f.p,
+		Time:       f.fix.Time.Add(now.Sub(f.fixSeen)),
+	}
+	return state, state.PosError() <= f.MaxError
+}
+
+func (f *Filter) addFix(pos GPS.Position, now time.Time) {
+	f.fix = pos
+	f.fixSeen = now
+	course := pos.Speed/3.6 > 3
+	if !f.started || Blitzer.GetDist(f.ref, pos.CurrPos) > 50 {
+		f.start(pos, now)
+		return
+	}
+
+	f.predict(now)
+	east, north := f.toLocal(pos.CurrPos)
+	noise := getFixNoise(pos.Quality)
+	f.correct(stateEast, east, noise*noise)
+	f.correct(stateNorth, north, noise*noise)
+	f.correct(stateSpeed, pos.Speed/3.6, f.GPSSpeedNoise*f.GPSSpeedNoise)
+	// the course is noise when standing still
+	if course {
+		f.correct(stateHeading, pos.Heading*math.Pi/180, f.CourseNoise*f.CourseNoise)
+	}
+}
+
+// (Re)starts the filter on a fix, also when we got too far from the reference point for a flat earth
+func (f *Filter) start(pos GPS.Position, now time.Time) {
+	noise := getFixNoise(pos.Quality)
+	f.ref = pos.CurrPos
+	f.x = [stateCount]float64{0, 0, pos.Heading * math.Pi / 180, pos.Speed / 3.6, 0}
+	f.p = matrix{}
+	f.p[stateEast][stateEast] = noise * noise
+	f.p[stateNorth][stateNorth] = noise * noise
+	f.p[stateHeading][stateHeading] = maxHeadingVariance
+	if pos.Speed/3.6 > 3 {
+		f.p[stateHeading][stateHeading] = f.CourseNoise * f.CourseNoise
+	}
+	f.p[stateSpeed][stateSpeed] = f.GPSSpeedNoise * f.GPSSpeedNoise
+	f.p[stateYawRate][stateYawRate] = 0.01
+	f.at = now
+	f.started = true
+}
+
+// Returns the standard deviation of a fix in m from the GGA quality
+func getFixNoise(quality int) float64 {
+	switch quality {
+	case 2:
+		// DGPS, SBAS
+		return 2
+	case 4, 5:
+		// RTK
+		return 0.5
+	}
+	return 5
+}
+
+// Moves the state forward to now
+func (f *Filter) predict(now time.Time) {
+	dt := now.Sub(f.at).Seconds()
+	if dt <= 0 {
+		return
+	}
+	f.at = now
+
+	heading := f.x[stateHeading]
+	speed := f.x[stateSpeed]
+	sin, cos := math.Sincos(heading)
+	f.x[stateEast] += speed * sin * dt
+	f.x[stateNorth] += speed * cos * dt
+	f.x[stateHeading] = normalizeAngle(heading + f.x[stateYawRate]*dt)
+	f.x[stateSpeed] = math.Max(0, speed)
+
+	// Jacobian of the motion
+	jacobian := identity()
+	jacobian[stateEast][stateHeading] = speed * cos * dt
+	jacobian[stateEast][stateSpeed] = sin * dt
+	jacobian[stateNorth][stateHeading] = -speed * sin * dt
+	jacobian[stateNorth][stateSpeed] = cos * dt
+	jacobian[stateHeading][stateYawRate] = dt
+
+	f.p = multiply(multiply(jacobian, f.p), transpose(jacobian))
+	// Unknown acceleration moves speed and position, unknown turning moves yaw rate and heading. Continuous white
+	// noise, so the uncertainty after a while is the same no matter how often predict ran in between.
+	accel := f.AccelNoise * f.AccelNoise
+	turn := f.TurnNoise * f.TurnNoise
+	dt2 := dt * dt / 2
+	dt3 := dt * dt * dt / 3
+	f.p[stateEast][stateEast] += accel * dt3
+	f.p[stateNorth][stateNorth] += accel * dt3
+	f.p[stateSpeed][stateSpeed] += accel * dt
+	f.p[stateEast][stateSpeed] += accel * dt2 * sin
+	f.p[stateSpeed][stateEast] += accel * dt2 * sin
+	f.p[stateNorth][stateSpeed] += accel * dt2 * cos
+	f.p[stateSpeed][stateNorth] += accel * dt2 * cos
+	f.p[stateHeading][stateHeading] += turn * dt3
+	f.p[stateYawRate][stateYawRate] += turn * dt
+	f.p[stateHeading][stateYawRate] += turn * dt2
+	f.p[stateYawRate][stateHeading] += turn * dt2
+
+	// Beyond half a turn the heading is just unknown, larger numbers would only blow up the position
+	if variance := f.p[stateHeading][stateHeading]; variance > maxHeadingVariance {
+		scale := math.Sqrt(maxHeadingVariance / variance)
+		for i := range f.p {
+			f.p[stateHeading][i] *= scale
+			f.p[i][stateHeading] *= scale
+		}
+	}
+}
+
+// Kalman update for a measurement of a single state variable with the given variance
+func (f *Filter) correct(index int, value float64, variance float64) {
+	innovation := value - f.x[index]
+	if index == stateHeading {
+		innovation = normalizeAngle(innovation)
+	}
+	s := f.p[index][index] + variance
+	gain := [stateCount]float64{}
+	for i := range gain {
+		gain[i] = f.p[i][index] / s
+	}
+	for i := range f.x {
+		f.x[i] += gain[i] * innovation
+	}
+	f.x[stateHeading] = normalizeAngle(f.x[stateHeading])
+
+	// P = (I - K H) P, H picks the row of index
+	row := f.p[index]
+	for i := range f.p {
+		for j := range f.p[i] {
+			f.p[i][j] -= gain[i] * row[j]
+		}
+	}
+}
+
+// Flat earth around the reference point, good for a few tens of km
+func (f *Filter) toLocal(pos [2]float64) (float64, float64) {
+	east := (pos[1] - f.ref[1]) * 111320 * math.Cos(f.ref[0]*math.Pi/180)
+	north := (pos[0] - f.ref[0]) * 110540
+	return east, north
+}
+
+func (f *Filter) toLatLng(east float64, north float64) [2]float64 {
+	return [2]float64{f.ref[0] + north/110540, f.ref[1] + east/(111320*math.Cos(f.ref[0]*math.Pi/180))}
+}
+
+// Returns the angle in (-pi, pi]
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle+math.Pi, 2*math.Pi)
+	if angle <= 0 {
+		angle += 2 * math.Pi
+	}
+	return angle - math.Pi
+}
+
+func identity() matrix {
+	m := matrix{}
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+func multiply(a matrix, b matrix) matrix {
+	m := matrix{}
+	for i := range m {
+		for j := range m[i] {
+			for k := range a[i] {
+				m[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return m
+}
+
+func transpose(a matrix) matrix {
+	m := matrix{}
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] = a[j][i]
+		}
+	}
+	return m
+}
+
+func (f *Filter) Run() error {
+	return f.GPS.Run()
+}
+
+func (f *Filter) Close() error {
+	return f.GPS.Close()
+}
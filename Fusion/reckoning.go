@@ -0,0 +1,98 @@
+package fusion
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	GPS "FesterBlitzer/GPS"
+	"sync"
+	"time"
+)
+
+// DeadReckoning moves the last GPS fix along its heading by the distance the OBD speed says we drove,
+// so the position keeps moving between 1 Hz fixes and in tunnels. It is a GPS.Provider itself.
+type DeadReckoning struct {
+	GPS GPS.Provider
+	// How long we keep extrapolating without a new fix
+	MaxLoss time.Duration
+	// OBD speed older than this is ignored and the GPS speed is used instead
+	MaxSpeedAge time.Duration
+
+	mu         sync.Mutex
+	fix        GPS.Position
+	hasFix     bool
+	fixSeen    time.Time
+	travelled  float64
+	integrated time.Time
+	speed      float64
+	speedAt    time.Time
+}
+
+func NewDeadReckoning(gps GPS.Provider) *DeadReckoning {
+	return &DeadReckoning{
+		GPS:         gps,
+		MaxLoss:     time.Minute,
+		MaxSpeedAge: time.Second,
+	}
+}
+
+// Sets the current speed in km/h, call with every OBD reading
+func (d *DeadReckoning) SetSpeed(speed float64, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// the old speed counts up to now
+	d.integrate(at)
+	d.speed = speed
+	d.speedAt = at
+}
+
+func (d *DeadReckoning) Position() (GPS.Position, bool) {
+	return d.PositionAt(time.Now())
+}
+
+// Returns the extrapolated position, false once the fix is older than MaxLoss
+func (d *DeadReckoning) PositionAt(now time.Time) (GPS.Position, bool) {
+	pos, ok := d.GPS.Position()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ok && (!d.hasFix || !pos.Time.Equal(d.fix.Time)) {
+		d.fix = pos
+		d.hasFix = true
+		d.fixSeen = now
+		d.travelled = 0
+		d.integrated = now
+	}
+	if !d.hasFix || now.Sub(d.fixSeen) > d.MaxLoss {
+		return pos, false
+	}
+
+	d.integrate(now)
+	result := d.fix
+	result.CurrPos = Blitzer.GetDestination(d.fix.CurrPos, d.fix.Heading, d.travelled)
+	result.Speed = d.getSpeed(now)
+	result.Time = d.fix.Time.Add(now.Sub(d.fixSeen))
+	return result, true
+}
+
+func (d *DeadReckoning) getSpeed(now time.Time) float64 {
+	if now.Sub(d.speedAt) <= d.MaxSpeedAge {
+		return d.speed
+	}
+	return d.fix.Speed
+}
+
+// Adds the distance driven since the last call
+func (d *DeadReckoning) integrate(now time.Time) {
+	if !d.hasFix || now.Before(d.integrated) {
+		return
+	}
+	d.travelled += d.getSpeed(d.integrated) * now.Sub(d.integrated).Hours()
+	d.integrated = now
+}
+
+func (d *DeadReckoning) Run() error {
+	return d.GPS.Run()
+}
+
+func (d *DeadReckoning) Close() error {
+	return d.GPS.Close()
+}
@@ -0,0 +1,105 @@
+package gps
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimum distance in km between lastPos and currPos, otherwise the scan box has no direction
+const minStep = 0.01
+
+type Position struct {
+	LastPos    [2]float64
+	CurrPos    [2]float64
+	Heading    float64 // degrees, 0 = north
+	Speed      float64 // km/h
+	Quality    int     // GGA fix quality, 0 = no fix
+	Satellites int
+	Time       time.Time
+}
+
+// Provider is anything that knows where we are
+type Provider interface {
+	// Returns the latest position, false as long as there is no usable fix
+	Position() (Position, bool)
+	// Reads from the receiver until it is closed
+	Run() error
+	Close() error
+}
+
+// Returns the provider for path, file:// replays a recorded NMEA log, gpsd://host:port connects to gpsd,
+// everything else is a serial receiver
+func Open(path string) (Provider, error) {
+	if strings.HasPrefix(path, "file://") {
+		return NewNMEAReplay(strings.TrimPrefix(path, "file://"))
+	}
+	if strings.HasPrefix(path, "gpsd://") {
+		return NewGpsd(strings.TrimPrefix(path, "gpsd://")), nil
+	}
+	return NewNMEA(path, 9600)
+}
+
+// tracker keeps lastPos/currPos up to date, shared by all backends
+type tracker struct {
+	mu      sync.Mutex
+	pos     Position
+	hasLast bool
+	hasCurr bool
+	course  bool
+	anchor  [2]float64
+}
+
+func (t *tracker) Position() (Position, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pos, t.hasLast && t.hasCurr && t.pos.Quality > 0
+}
+
+// Moves currPos, lastPos only follows once we moved far enough to get a direction
+func (t *tracker) update(pos [2]float64, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.hasCurr {
+		t.pos.CurrPos = pos
+		t.anchor = pos
+		t.hasCurr = true
+		t.pos.Time = at
+		return
+	}
+	if Blitzer.GetDist(t.anchor, pos) >= minStep {
+		t.pos.LastPos = t.anchor
+		t.anchor = pos
+		t.hasLast = true
+		if !t.course {
+			t.pos.Heading = Blitzer.GetBearing(t.pos.LastPos, pos)
+		}
+	}
+	t.pos.CurrPos = pos
+	t.pos.Time = at
+}
+
+func (t *tracker) setCourse(heading float64, speed float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pos.Heading = heading
+	t.pos.Speed = speed
+	t.course = true
+}
+
+func (t *tracker) setQuality(quality int, satellites int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pos.Quality = quality
+	t.pos.Satellites = satellites
+}
+
+// RMC only receivers never send a quality, a valid RMC counts as GPS fix
+func (t *tracker) markValid() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pos.Quality == 0 {
+		t.pos.Quality = 1
+	}
+}
@@ -0,0 +1,163 @@
+package gps
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultGpsdAddr = "localhost:2947"
+
+// Gpsd shares the receiver with other programs (e.g. navigation) through a running gpsd
+type Gpsd struct {
+	tracker
+	addr string
+
+	connMu sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// gpsd leaves out fields the receiver didn't send, standing still often has no track
+type gpsdReport struct {
+	Class      string   `json:"class"`
+	Mode       int      `json:"mode"`
+	Status     int      `json:"status"`
+	Time       string   `json:"time"`
+	Lat        float64  `json:"lat"`
+	Lon        float64  `json:"lon"`
+	Speed      *float64 `json:"speed"` // m/s
+	Track      *float64 `json:"track"`
+	USat       int      `json:"uSat"`
+	Satellites []struct {
+		Used bool `json:"used"`
+	} `json:"satellites"`
+}
+
+// Returns a gpsd client for host:port, nothing is connected before Run
+func NewGpsd(addr string) *Gpsd {
+	if addr == "" {
+		addr = DefaultGpsdAddr
+	} else if !strings.Contains(addr, ":") {
+		addr += ":2947"
+	}
+	return &Gpsd{addr: addr}
+}
+
+// Keeps reading reports, reconnects with backoff whenever gpsd goes away
+func (g *Gpsd) Run() error {
+	backoff := time.Second
+	for {
+		conn, err := net.DialTimeout("tcp", g.addr, 5*time.Second)
+		if err == nil {
+			if !g.setConn(conn) {
+				conn.Close()
+				return nil
+			}
+			backoff = time.Second
+			err = g.watch(conn)
+		}
+		if g.isClosed() {
+			return nil
+		}
+
+		print("GPSD OFF: ", err.Error(), "\n")
+		g.setQuality(0, 0)
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (g *Gpsd) Close() error {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	g.closed = true
+	if g.conn != nil {
+		return g.conn.Close()
+	}
+	return nil
+}
+
+func (g *Gpsd) setConn(conn net.Conn) bool {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	g.conn = conn
+	return !g.closed
+}
+
+func (g *Gpsd) isClosed() bool {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	return g.closed
+}
+
+// Enables JSON watch mode and handles reports until the connection breaks
+func (g *Gpsd) watch(conn net.Conn) error {
+	defer conn.Close()
+	_, err := conn.Write([]byte("?WATCH={\"enable\":true,\"json\":true}\n"))
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for {
+		// gpsd sends at least one TPV per second, silence means the daemon hangs
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return net.ErrClosed
+		}
+		var report gpsdReport
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			continue
+		}
+		g.handle(report)
+	}
+}
+
+func (g *Gpsd) handle(report gpsdReport) {
+	switch report.Class {
+	case "TPV":
+		// mode 0/1 = no fix, 2 = 2D, 3 = 3D; status 2 = DGPS
+		if report.Mode < 2 {
+			g.setQuality(0, 0)
+			return
+		}
+		quality := 1
+		if report.Status == 2 {
+			quality = 2
+		}
+		g.mu.Lock()
+		satellites := g.pos.Satellites
+		g.mu.Unlock()
+		g.setQuality(quality, satellites)
+		if report.Track != nil && report.Speed != nil {
+			g.setCourse(*report.Track, *report.Speed*3.6)
+		}
+
+		at, err := time.Parse(time.RFC3339, report.Time)
+		if err != nil {
+			at = time.Now()
+		}
+		g.update([2]float64{report.Lat, report.Lon}, at)
+	case "SKY":
+		used := report.USat
+		if used == 0 {
+			for _, satellite := range report.Satellites {
+				if satellite.Used {
+					used++
+				}
+			}
+		}
+		g.mu.Lock()
+		g.pos.Satellites = used
+		g.mu.Unlock()
+	}
+}
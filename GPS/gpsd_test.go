@@ -0,0 +1,186 @@
+package gps
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGpsd answers every connection with the next list of reports and hangs up
+type fakeGpsd struct {
+	listener net.Listener
+	watch    chan string
+}
+
+func newFakeGpsd(t *testing.T, sessions ...[]string) *fakeGpsd {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeGpsd{listener: listener, watch: make(chan string, len(sessions))}
+	go func() {
+		for _, reports := range sessions {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			f.watch <- line
+			for _, report := range reports {
+				conn.Write([]byte(report + "\n"))
+			}
+			time.Sleep(50 * time.Millisecond)
+			conn.Close()
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+// Waits until the provider reports a position that passes check
+func waitFor(t *testing.T, g *Gpsd, check func(Position, bool) bool) Position {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if pos, ok := g.Position(); check(pos, ok) {
+			return pos
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pos, ok := g.Position()
+	t.Fatalf("timed out, last position %+v, ok %v", pos, ok)
+	return pos
+}
+
+func TestNewGpsdAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		"":               DefaultGpsdAddr,
+		"raspberrypi":    "raspberrypi:2947",
+		"10.0.0.2:12345": "10.0.0.2:12345",
+	} {
+		if got := NewGpsd(addr).addr; got != want {
+			t.Errorf("NewGpsd(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestGpsdWatch(t *testing.T) {
+	f := newFakeGpsd(t, []string{
+		`{"class":"VERSION","release":"3.25"}`,
+		`{"class":"SKY","uSat":8}`,
+		`{"class":"TPV","mode":3,"status":2,"time":"2025-01-13T10:10:00.000Z","lat":48.5212,"lon":8.8684,"speed":13.9,"track":170.2}`,
+		`{"class":"TPV","mode":3,"status":2,"time":"2025-01-13T10:10:01.000Z","lat":48.5200,"lon":8.8688,"speed":13.9,"track":170.2}`,
+	})
+	g := NewGpsd(f.listener.Addr().String())
+	go g.Run()
+	defer g.Close()
+
+	select {
+	case line := <-f.watch:
+		if !strings.HasPrefix(line, "?WATCH=") || !strings.Contains(line, `"json":true`) {
+			t.Errorf("watch request %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch request")
+	}
+
+	pos := waitFor(t, g, func(pos Position, ok bool) bool { return ok })
+	if pos.CurrPos != [2]float64{48.5200, 8.8688} || pos.LastPos != [2]float64{48.5212, 8.8684} {
+		t.Errorf("LastPos %v, CurrPos %v", pos.LastPos, pos.CurrPos)
+	}
+	if pos.Quality != 2 || pos.Satellites != 8 {
+		t.Errorf("Quality %d, Satellites %d", pos.Quality, pos.Satellites)
+	}
+	if pos.Heading != 170.2 || pos.Speed < 50 || pos.Speed > 50.1 {
+		t.Errorf("Heading %v, Speed %v", pos.Heading, pos.Speed)
+	}
+}
+
+func TestGpsdReconnect(t *testing.T) {
+	f := newFakeGpsd(t,
+		[]string{
+			`{"class":"TPV","mode":3,"lat":48.5212,"lon":8.8684,"speed":13.9,"track":170.2}`,
+			`{"class":"TPV","mode":3,"lat":48.5200,"lon":8.8688,"speed":13.9,"track":170.2}`,
+		},
+		[]string{
+			`{"class":"TPV","mode":3,"lat":48.5180,"lon":8.8695,"speed":13.9,"track":170.2}`,
+		},
+	)
+	g := NewGpsd(f.listener.Addr().String())
+	go g.Run()
+	defer g.Close()
+
+	waitFor(t, g, func(pos Position, ok bool) bool { return ok })
+	// gpsd hangs up, the fix is gone until the next connection delivers
+	waitFor(t, g, func(pos Position, ok bool) bool { return !ok })
+	pos := waitFor(t, g, func(pos Position, ok bool) bool { return ok && pos.CurrPos[0] == 48.5180 })
+	if len(f.watch) != 2 {
+		t.Errorf("%d watch requests, want one per connection", len(f.watch))
+	}
+	if pos.LastPos != [2]float64{48.5200, 8.8688} {
+		t.Errorf("LastPos %v after reconnect", pos.LastPos)
+	}
+}
+
+// Returns a pointer for the optional report fields
+func ptr(value float64) *float64 {
+	return &value
+}
+
+func TestGpsdNoFix(t *testing.T) {
+	g := NewGpsd("")
+	g.handle(gpsdReport{Class: "TPV", Mode: 3, Lat: 48.5212, Lon: 8.8684, Speed: ptr(13.9), Track: ptr(170.2)})
+	g.handle(gpsdReport{Class: "TPV", Mode: 3, Lat: 48.5200, Lon: 8.8688, Speed: ptr(13.9), Track: ptr(170.2)})
+	if _, ok := g.Position(); !ok {
+		t.Fatal("no fix after two 3D reports")
+	}
+	g.handle(gpsdReport{Class: "TPV", Mode: 1})
+	if pos, ok := g.Position(); ok || pos.Quality != 0 {
+		t.Errorf("fix after mode 1, Quality %d", pos.Quality)
+	}
+}
+
+func TestGpsdNoTrack(t *testing.T) {
+	g := NewGpsd("")
+	g.handle(gpsdReport{Class: "TPV", Mode: 3, Lat: 48.5212, Lon: 8.8684, Speed: ptr(13.9), Track: ptr(170.2)})
+	// standing still, gpsd sends the speed but no track
+	g.handle(gpsdReport{Class: "TPV", Mode: 3, Lat: 48.5200, Lon: 8.8688, Speed: ptr(0)})
+	pos, ok := g.Position()
+	if !ok {
+		t.Fatal("no fix after two 3D reports")
+	}
+	if pos.Heading != 170.2 || pos.Speed < 50 || pos.Speed > 50.1 {
+		t.Errorf("Heading %v, Speed %v, a report without track must keep the course", pos.Heading, pos.Speed)
+	}
+}
+
+func TestGpsdClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			// never answers
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	g := NewGpsd(listener.Addr().String())
+	done := make(chan error)
+	go func() { done <- g.Run() }()
+	time.Sleep(50 * time.Millisecond)
+	g.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v after Close", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run still running after Close")
+	}
+}
@@ -0,0 +1,206 @@
+package gps
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+var ErrChecksum = errors.New("nmea: checksum mismatch")
+
+// NMEA reads NMEA 0183 sentences ($GPRMC, $GPGGA, $GPVTG) from a receiver or a recorded log
+type NMEA struct {
+	tracker
+	source io.ReadCloser
+	replay bool
+}
+
+// Opens a serial GPS receiver, a pty works as well
+func NewNMEA(path string, baud int) (*NMEA, error) {
+	port, err := serial.OpenPort(&serial.Config{Name: path, Baud: baud})
+	if err != nil {
+		return nil, err
+	}
+	return &NMEA{source: port}, nil
+}
+
+// Opens a recorded NMEA log which is played back at one fix per second and starts over at the end
+func NewNMEAReplay(path string) (*NMEA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &NMEA{source: file, replay: true}, nil
+}
+
+// Reads sentences until the source is closed
+func (n *NMEA) Run() error {
+	for {
+		scanner := bufio.NewScanner(n.source)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			err := n.Parse(line)
+			if err != nil {
+				print(err.Error(), "\n")
+				continue
+			}
+			if n.replay && strings.HasSuffix(sentenceType(line), "RMC") {
+				time.Sleep(time.Second)
+			}
+		}
+		if err := scanner.Err(); err != nil || !n.replay {
+			return err
+		}
+		// Recorded log: start from the beginning again
+		seeker, ok := n.source.(io.Seeker)
+		if !ok {
+			return nil
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+}
+
+func (n *NMEA) Close() error {
+	return n.source.Close()
+}
+
+// Parses a single sentence and updates the position
+func (n *NMEA) Parse(line string) error {
+	fields, err := splitSentence(line)
+	if err != nil {
+		return err
+	}
+
+	switch sentenceType(line) {
+	case "GPRMC", "GNRMC":
+		// $GPRMC,hhmmss.ss,A,llll.ll,a,yyyyy.yy,a,x.x,x.x,ddmmyy,x.x,a
+		if len(fields) < 10 {
+			return fmt.Errorf("nmea: short RMC sentence")
+		}
+		if fields[2] != "A" {
+			n.setQuality(0, 0)
+			return nil
+		}
+		pos, err := parseLatLng(fields[3], fields[4], fields[5], fields[6])
+		if err != nil {
+			return err
+		}
+		knots, err1 := strconv.ParseFloat(fields[7], 64)
+		course, err2 := strconv.ParseFloat(fields[8], 64)
+		if err1 == nil && err2 == nil {
+			n.setCourse(course, knots*1.852)
+		}
+		at := parseTime(fields[9], fields[1])
+		if n.replay {
+			at = time.Now()
+		}
+		n.markValid()
+		n.update(pos, at)
+	case "GPGGA", "GNGGA":
+		// $GPGGA,hhmmss.ss,llll.ll,a,yyyyy.yy,a,q,ss,...
+		if len(fields) < 8 {
+			return fmt.Errorf("nmea: short GGA sentence")
+		}
+		quality, _ := strconv.Atoi(fields[6])
+		satellites, _ := strconv.Atoi(fields[7])
+		n.setQuality(quality, satellites)
+	case "GPVTG", "GNVTG":
+		// $GPVTG,x.x,T,x.x,M,x.x,N,x.x,K
+		if len(fields) < 8 {
+			return fmt.Errorf("nmea: short VTG sentence")
+		}
+		course, err1 := strconv.ParseFloat(fields[1], 64)
+		kmh, err2 := strconv.ParseFloat(fields[7], 64)
+		if err1 == nil && err2 == nil {
+			n.setCourse(course, kmh)
+		}
+	}
+	return nil
+}
+
+// Returns e.g. "GPRMC" for "$GPRMC,..."
+func sentenceType(line string) string {
+	end := strings.IndexByte(line, ',')
+	if !strings.HasPrefix(line, "$") || end < 0 {
+		return ""
+	}
+	return line[1:end]
+}
+
+// Checks the checksum and returns the comma separated fields
+func splitSentence(line string) ([]string, error) {
+	if !strings.HasPrefix(line, "$") {
+		return nil, fmt.Errorf("nmea: not a sentence: %q", line)
+	}
+	body := line[1:]
+	if star := strings.LastIndexByte(body, '*'); star >= 0 {
+		want, err := strconv.ParseUint(body[star+1:], 16, 8)
+		if err != nil {
+			return nil, ErrChecksum
+		}
+		body = body[:star]
+		sum := byte(0)
+		for i := 0; i < len(body); i++ {
+			sum ^= body[i]
+		}
+		if sum != byte(want) {
+			return nil, ErrChecksum
+		}
+	}
+	return strings.Split(body, ","), nil
+}
+
+// Converts ddmm.mmmm / dddmm.mmmm with hemisphere into decimal degrees
+func parseLatLng(lat string, ns string, lng string, ew string) ([2]float64, error) {
+	la, err := parseDegrees(lat, 2)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	lo, err := parseDegrees(lng, 3)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	if ns == "S" {
+		la = -la
+	}
+	if ew == "W" {
+		lo = -lo
+	}
+	return [2]float64{la, lo}, nil
+}
+
+func parseDegrees(value string, degreeDigits int) (float64, error) {
+	if len(value) < degreeDigits {
+		return 0, fmt.Errorf("nmea: bad coordinate %q", value)
+	}
+	degrees, err := strconv.ParseFloat(value[:degreeDigits], 64)
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseFloat(value[degreeDigits:], 64)
+	if err != nil {
+		return 0, err
+	}
+	return degrees + minutes/60, nil
+}
+
+// Returns the fix time, falls back to now for receivers without date
+func parseTime(date string, clock string) time.Time {
+	t, err := time.Parse("020106 150405", date+" "+strings.SplitN(clock, ".", 2)[0])
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
@@ -0,0 +1,149 @@
+package gps
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Appends the checksum to a sentence without one
+func withChecksum(sentence string) string {
+	sum := byte(0)
+	for i := 1; i < len(sentence); i++ {
+		sum ^= sentence[i]
+	}
+	return fmt.Sprintf("%s*%02X", sentence, sum)
+}
+
+func TestParseRMC(t *testing.T) {
+	n := &NMEA{}
+	if err := n.Parse("$GPRMC,101000.00,A,4831.2760,N,00852.1086,E,27.0,170.2,130125,,,A*66"); err != nil {
+		t.Fatal(err)
+	}
+	pos := n.pos
+	if math.Abs(pos.CurrPos[0]-48.52127) > 1e-5 || math.Abs(pos.CurrPos[1]-8.86848) > 1e-5 {
+		t.Errorf("CurrPos = %v", pos.CurrPos)
+	}
+	if math.Abs(pos.Speed-50.004) > 1e-3 || pos.Heading != 170.2 {
+		t.Errorf("Speed = %v, Heading = %v", pos.Speed, pos.Heading)
+	}
+	if pos.Quality != 1 {
+		t.Errorf("Quality = %d, a valid RMC counts as fix", pos.Quality)
+	}
+	if got := pos.Time.Format("2006-01-02 15:04:05"); got != "2025-01-13 10:10:00" {
+		t.Errorf("Time = %s", got)
+	}
+}
+
+func TestParseSouthWest(t *testing.T) {
+	n := &NMEA{}
+	if err := n.Parse(withChecksum("$GNRMC,120000,A,3352.0000,S,15112.0000,W,0.0,0.0,010125,,")); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(n.pos.CurrPos[0]+33.86667) > 1e-5 || math.Abs(n.pos.CurrPos[1]+151.2) > 1e-5 {
+		t.Errorf("CurrPos = %v", n.pos.CurrPos)
+	}
+}
+
+func TestParseGGA(t *testing.T) {
+	n := &NMEA{}
+	if err := n.Parse("$GPGGA,101000.00,4831.2760,N,00852.1086,E,1,09,0.9,420.0,M,48.0,M,,*6B"); err != nil {
+		t.Fatal(err)
+	}
+	if n.pos.Quality != 1 || n.pos.Satellites != 9 {
+		t.Errorf("Quality = %d, Satellites = %d", n.pos.Quality, n.pos.Satellites)
+	}
+	if err := n.Parse(withChecksum("$GPGGA,101001.00,4831.2760,N,00852.1086,E,2,12,0.7,420.0,M,48.0,M,,")); err != nil {
+		t.Fatal(err)
+	}
+	if n.pos.Quality != 2 || n.pos.Satellites != 12 {
+		t.Errorf("Quality = %d, Satellites = %d", n.pos.Quality, n.pos.Satellites)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	n := &NMEA{}
+	err := n.Parse("$GPRMC,101000.00,A,4831.2760,N,00852.1086,E,27.0,170.2,130125,,,A*67")
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("err = %v, want ErrChecksum", err)
+	}
+	err = n.Parse("$GPRMC,101000.00,A,4831.2760,N,00852.1086,E,27.0,170.2,130125,,,A*XY")
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("err = %v, want ErrChecksum", err)
+	}
+	if n.hasCurr {
+		t.Error("a rejected sentence moved the position")
+	}
+}
+
+func TestParseNoFix(t *testing.T) {
+	n := &NMEA{}
+	lines := []string{
+		"$GPRMC,101000.00,A,4831.2760,N,00852.1086,E,27.0,170.2,130125,,,A*66",
+		"$GPRMC,101001.00,A,4831.2682,N,00852.1106,E,27.0,170.2,130125,,,A*63",
+	}
+	for _, line := range lines {
+		if err := n.Parse(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := n.Position(); !ok {
+		t.Fatal("no fix after two valid RMC")
+	}
+
+	// receiver lost the sky: RMC status V and GGA quality 0
+	if err := n.Parse(withChecksum("$GPRMC,101002.00,V,,,,,,,130125,,,N")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.Position(); ok {
+		t.Error("fix after RMC status V")
+	}
+	if err := n.Parse(withChecksum("$GPGGA,101002.00,,,,,0,00,99.9,,,,,,")); err != nil {
+		t.Fatal(err)
+	}
+	if pos, ok := n.Position(); ok || pos.Quality != 0 {
+		t.Errorf("Quality = %d after GGA without fix", pos.Quality)
+	}
+}
+
+func TestParseShort(t *testing.T) {
+	n := &NMEA{}
+	if err := n.Parse(withChecksum("$GPRMC,101000.00,A")); err == nil {
+		t.Error("short RMC accepted")
+	}
+	if err := n.Parse("GPRMC,101000.00"); err == nil {
+		t.Error("line without $ accepted")
+	}
+}
+
+func TestParseRecording(t *testing.T) {
+	data, err := os.ReadFile("../Assets/hailfingen.nmea")
+	if err != nil {
+		t.Skip(err)
+	}
+	n := &NMEA{}
+	fixes := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := n.Parse(line); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if _, ok := n.Position(); ok {
+			fixes++
+		}
+	}
+	if fixes == 0 {
+		t.Fatal("no fix in the recording")
+	}
+	pos, _ := n.Position()
+	// the recording drives through Hailfingen
+	if math.Abs(pos.CurrPos[0]-48.5) > 0.1 || math.Abs(pos.CurrPos[1]-8.87) > 0.1 {
+		t.Errorf("CurrPos = %v", pos.CurrPos)
+	}
+}
@@ -0,0 +1,42 @@
+package obd
+
+import (
+	"github.com/rzetterberg/elmobd"
+)
+
+// Asks the car which Mode 01 PIDs it has (bitmaps 0x00, 0x20, 0x40, ...) and prints them with a first
+// reading, like onlyFilterSupported.txt
+func (s *Session) discover(device *elmobd.Device) {
+	supported, err := device.CheckSupportedCommands()
+	if err != nil {
+		print("PID discovery failed, polling everything: ", err.Error(), "\n")
+		return
+	}
+	s.mu.Lock()
+	s.supported = supported
+	s.mu.Unlock()
+
+	for _, command := range supported.FilterSupported(elmobd.GetSensorCommands()) {
+		result, err := device.RunOBDCommand(command)
+		if err != nil {
+			print(command.Key(), " failed: ", err.Error(), "\n")
+			continue
+		}
+		print(result.Key(), " outputs: ", result.ValueAsLit(), "\n")
+	}
+}
+
+// Returns whether the car has the metric, true as long as we don't know
+func (s *Session) IsSupported(metric Metric) bool {
+	s.mu.Lock()
+	supported := s.supported
+	s.mu.Unlock()
+	if supported == nil {
+		return true
+	}
+	pid := GetPID(metric)
+	if pid.New == nil {
+		return false
+	}
+	return supported.IsSupported(pid.New())
+}
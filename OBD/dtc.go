@@ -0,0 +1,298 @@
+package obd
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rzetterberg/elmobd"
+)
+
+var ErrClearUnsafe = errors.New("obd: only clear codes standing with the engine off")
+
+// DTCKind is where a trouble code comes from
+type DTCKind int
+
+const (
+	// Mode 03, confirmed, usually with the MIL on
+	DTCStored DTCKind = iota
+	// Mode 07, seen once in the current or last drive cycle
+	DTCPending
+	// Mode 0A, can't be cleared by Mode 04, only by the ECU after the repair is confirmed
+	DTCPermanent
+)
+
+func (k DTCKind) String() string {
+	switch k {
+	case DTCPending:
+		return "pending"
+	case DTCPermanent:
+		return "permanent"
+	}
+	return "stored"
+}
+
+// Mode to request each kind
+var dtcModes = map[DTCKind]byte{DTCStored: 0x03, DTCPending: 0x07, DTCPermanent: 0x0A}
+
+type DTC struct {
+	Code        string
+	Description string
+	Kind        DTCKind
+}
+
+// RawCommander sends a command string like "03" to the adapter and returns the answer lines.
+// elmobd only runs Mode 01 commands, so the trouble codes are read through Session.Raw.
+type RawCommander interface {
+	RunRawCommand(command string) ([]string, error)
+}
+
+//go:embed dtc.csv
+var dtcTable string
+
+var descriptions = sync.OnceValue(func() map[string]string {
+	records, err := csv.NewReader(strings.NewReader(dtcTable)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	table := map[string]string{}
+	for _, record := range records {
+		table[record[0]] = record[1]
+	}
+	return table
+})
+
+// Returns the code for the two bytes of a DTC, e.g. 0x01 0x33 is P0133
+func DecodeDTC(a byte, b byte) string {
+	system := "PCBU"[a>>6]
+	return fmt.Sprintf("%c%d%X%02X", system, (a>>4)&0x3, a&0xF, b)
+}
+
+// Returns the description of a code, generic codes not in the table get their system
+func Describe(code string) string {
+	if description, ok := descriptions()[code]; ok {
+		return description
+	}
+	systems := map[byte]string{'P': "Powertrain", 'C': "Chassis", 'B': "Body", 'U': "Network"}
+	system := systems[code[0]]
+	// the second digit 1 (and 3 for P) marks codes only the manufacturer documents
+	if code[1] == '1' || (code[0] == 'P' && code[1] == '3') {
+		return system + " code, manufacturer specific"
+	}
+	return system + " code"
+}
+
+// Returns the codes of a Mode 03, 07 or 0A answer. On CAN the mode byte is followed by the number of codes and
+// answers with three or more codes come in frames ("00A", "0: 43 04 01 33 ...", "1: ..."), the older protocols
+// send lines of three codes padded with zeros.
+func ParseDTCResponse(mode byte, lines []string, can bool) ([]string, error) {
+	codes := []string{}
+	var frames []byte
+	size := -1
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.EqualFold(line, "NO DATA") || strings.HasPrefix(line, "SEARCHING") {
+			continue
+		}
+		// multi frame: the first line is the byte count, then numbered frames
+		if index, rest, ok := strings.Cut(line, ":"); ok && len(index) <= 2 {
+			data, err := hex.DecodeString(strings.ReplaceAll(rest, " ", ""))
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, data...)
+			continue
+		}
+		line = strings.ReplaceAll(line, " ", "")
+		if len(line) == 3 {
+			count, err := strconv.ParseUint(line, 16, 16)
+			if err != nil {
+				return nil, err
+			}
+			size = int(count)
+			continue
+		}
+		data, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, parseDTCData(mode, data, can)...)
+	}
+	if frames != nil {
+		// the last frame is padded
+		if size >= 0 && size < len(frames) {
+			frames = frames[:size]
+		}
+		codes = append(codes, parseDTCData(mode, frames, true)...)
+	}
+	return codes, nil
+}
+
+func parseDTCData(mode byte, data []byte, can bool) []string {
+	if len(data) == 0 || data[0] != mode+0x40 {
+		return nil
+	}
+	data = data[1:]
+	if can {
+		if len(data) == 0 {
+			return nil
+		}
+		count := int(data[0])
+		data = data[1:]
+		data = data[:min(len(data), 2*count)]
+	}
+	codes := []string{}
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 && data[i+1] == 0 {
+			// padding
+			continue
+		}
+		codes = append(codes, DecodeDTC(data[i], data[i+1]))
+	}
+	return codes
+}
+
+// Returns whether the adapter talks CAN to the car, ATDPN answers the protocol number, "A6" if found automatically.
+// Cars without an answer are taken for CAN, which every car sold since 2008 speaks.
+func isCAN(raw RawCommander) bool {
+	lines, err := raw.RunRawCommand("ATDPN")
+	if err != nil || len(lines) == 0 {
+		return true
+	}
+	protocol, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(lines[0]), "A"), 16, 8)
+	if err != nil {
+		return true
+	}
+	// 6 to 9 are the OBD CAN protocols, A is J1939 and B and C user CAN
+	return protocol >= 6
+}
+
+// DiagnosticStatus is what the HUD shows about the engine light
+type DiagnosticStatus struct {
+	MIL bool
+	// Stored codes according to the ECU, also known without raw access
+	Count int
+	Codes []DTC
+	// Whether the codes could be read, without the MIL and Count are all we know
+	CanRead bool
+	Checked time.Time
+}
+
+// Diagnostics reads the MIL and the trouble codes every Interval. It has to run on the goroutine that polls,
+// an ELM327 only does one thing at a time.
+type Diagnostics struct {
+	Session  *Session
+	Interval time.Duration
+
+	mu     sync.Mutex
+	status DiagnosticStatus
+}
+
+func NewDiagnostics(session *Session) *Diagnostics {
+	return &Diagnostics{Session: session, Interval: 30 * time.Second}
+}
+
+func (d *Diagnostics) Status() DiagnosticStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// Returns whether the next check is due
+func (d *Diagnostics) IsDue(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return now.Sub(d.status.Checked) >= d.Interval
+}
+
+// Reads the MIL with Mode 01 PID 01 and, if the adapter allows, all stored, pending and permanent codes
+func (d *Diagnostics) Check(now time.Time) error {
+	status := DiagnosticStatus{Checked: now}
+	d.mu.Lock()
+	d.status.Checked = now
+	d.mu.Unlock()
+
+	response, err := d.Session.Run([]elmobd.OBDCommand{elmobd.NewMonitorStatus()})
+	if err != nil {
+		return err
+	}
+	if monitor, ok := response[0].(*elmobd.MonitorStatus); ok {
+		status.MIL = monitor.MilActive
+		status.Count = int(monitor.DtcAmount)
+	}
+
+	// A dead adapter shows up here as well, Fail reconnects then
+	raw, err := d.Session.Raw()
+	if err == nil {
+		status.Codes, err = readCodes(raw)
+		if err != nil {
+			d.Session.Fail(err)
+		}
+	}
+	if err != nil {
+		print("Could not read trouble codes: ", err.Error(), "\n")
+	}
+	status.CanRead = err == nil
+
+	d.mu.Lock()
+	d.status = status
+	d.mu.Unlock()
+	return nil
+}
+
+// Returns the stored, pending and permanent codes
+func readCodes(raw RawCommander) ([]DTC, error) {
+	dtcs := []DTC{}
+	can := isCAN(raw)
+	for _, kind := range []DTCKind{DTCStored, DTCPending, DTCPermanent} {
+		mode := dtcModes[kind]
+		lines, err := raw.RunRawCommand(fmt.Sprintf("%02X", mode))
+		if err != nil && kind == DTCPermanent && Classify(err) != KindDisconnected {
+			// permanent codes need a CAN car, older ones answer with an error
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		codes, err := ParseDTCResponse(mode, lines, can)
+		if err != nil {
+			print("Could not read ", kind.String(), " codes: ", err.Error(), "\n")
+			continue
+		}
+		for _, code := range codes {
+			dtcs = append(dtcs, DTC{Code: code, Description: Describe(code), Kind: kind})
+		}
+	}
+	return dtcs, nil
+}
+
+// Clears stored and pending codes and turns off the MIL with Mode 04. The ECU also resets its readiness
+// monitors, so this is refused unless the car stands with the engine off.
+func (d *Diagnostics) Clear(telemetry Telemetry) error {
+	if !telemetry.Has(MetricSpeed) || !telemetry.Has(MetricRPM) || telemetry.Speed > 0 || telemetry.RPM > 0 {
+		return ErrClearUnsafe
+	}
+	raw, err := d.Session.Raw()
+	if err != nil {
+		return err
+	}
+	lines, err := raw.RunRawCommand("04")
+	if err != nil {
+		d.Session.Fail(err)
+		return err
+	}
+	// 44 is the positive answer
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "44") {
+			print("Trouble codes cleared\n")
+			return d.Check(time.Now())
+		}
+	}
+	return fmt.Errorf("obd: clear answered %q", strings.Join(lines, " "))
+}
@@ -0,0 +1,132 @@
+package obd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDTC(t *testing.T) {
+	tests := []struct {
+		a, b byte
+		want string
+	}{
+		{0x01, 0x33, "P0133"},
+		{0x03, 0x00, "P0300"},
+		{0x41, 0x23, "C0123"},
+		{0x9A, 0xBC, "B1ABC"},
+		{0xC1, 0x00, "U0100"},
+	}
+	for _, test := range tests {
+		if got := DecodeDTC(test.a, test.b); got != test.want {
+			t.Errorf("DecodeDTC(%02X, %02X) = %s, want %s", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestParseDTCResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  byte
+		can   bool
+		lines []string
+		want  []string
+	}{
+		{"can single frame", 0x03, true, []string{"43 02 01 33 03 00"}, []string{"P0133", "P0300"}},
+		{"can one code", 0x07, true, []string{"47 01 01 71"}, []string{"P0171"}},
+		{"can no codes", 0x03, true, []string{"43 00"}, []string{}},
+		{"can no data", 0x0A, true, []string{"SEARCHING...", "NO DATA"}, []string{}},
+		{"can two ecus", 0x03, true, []string{"43 01 01 33", "43 01 07 00"}, []string{"P0133", "P0700"}},
+		{"can multi frame", 0x03, true, []string{"00A", "0: 43 04 01 33 03 00", "1: 01 71 01 72 00 00 00"},
+			[]string{"P0133", "P0300", "P0171", "P0172"}},
+		{"can multi frame padded with codes", 0x03, true, []string{"008", "0: 43 03 01 33 03 00", "1: 01 71 AA AA AA AA AA"},
+			[]string{"P0133", "P0300", "P0171"}},
+		{"can count below the data", 0x03, true, []string{"43 01 01 33 00 00"}, []string{"P0133"}},
+		{"pre-can one line", 0x03, false, []string{"43 01 33 00 00 00 00"}, []string{"P0133"}},
+		{"pre-can two lines", 0x03, false, []string{"43 01 33 03 00 01 71", "43 01 72 00 00 00 00"},
+			[]string{"P0133", "P0300", "P0171", "P0172"}},
+		{"other mode", 0x03, true, []string{"47 01 01 33"}, []string{}},
+	}
+	for _, test := range tests {
+		got, err := ParseDTCResponse(test.mode, test.lines, test.can)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseDTCResponseGarbage(t *testing.T) {
+	for _, lines := range [][]string{{"43 0X"}, {"0GA", "0: 43 01 01 33"}, {"0: 43 0Z"}} {
+		if _, err := ParseDTCResponse(0x03, lines, true); err == nil {
+			t.Errorf("%v accepted", lines)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	if got := Describe("P0300"); got != "Random/Multiple Cylinder Misfire Detected" {
+		t.Errorf("Describe(P0300) = %q", got)
+	}
+	if got := Describe("P1234"); got != "Powertrain code, manufacturer specific" {
+		t.Errorf("Describe(P1234) = %q", got)
+	}
+	if got := Describe("U0999"); got != "Network code" {
+		t.Errorf("Describe(U0999) = %q", got)
+	}
+}
+
+func TestReadCodes(t *testing.T) {
+	raw := &mockRaw{}
+	dtcs, err := readCodes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DTC{
+		{"P0133", Describe("P0133"), DTCStored},
+		{"P0300", Describe("P0300"), DTCPending},
+	}
+	if !slices.Equal(dtcs, want) {
+		t.Errorf("got %v, want %v", dtcs, want)
+	}
+
+	raw.RunRawCommand("04")
+	if dtcs, err := readCodes(raw); err != nil || len(dtcs) != 0 {
+		t.Errorf("after clearing got %v, %v", dtcs, err)
+	}
+}
+
+func TestIsCAN(t *testing.T) {
+	for answer, want := range map[string]bool{"A6": true, "6": true, "A3": false, "5": false, "B": true, "?": true} {
+		raw := fakeRaw{"ATDPN": {answer}}
+		if got := isCAN(raw); got != want {
+			t.Errorf("isCAN(%q) = %v, want %v", answer, got, want)
+		}
+	}
+}
+
+// fakeRaw answers every command from a table
+type fakeRaw map[string][]string
+
+func (f fakeRaw) RunRawCommand(command string) ([]string, error) {
+	return parseRawAnswer(command, command+"\r"+strings.Join(f[command], "\r")+"\r\r>")
+}
+
+func TestParseRawAnswer(t *testing.T) {
+	lines, err := parseRawAnswer("03", "03\rSEARCHING...\r43 01 01 33\r\r>")
+	if err != nil || !slices.Equal(lines, []string{"43 01 01 33"}) {
+		t.Errorf("got %v, %v", lines, err)
+	}
+	lines, err = parseRawAnswer("07", "NO DATA\r\r>")
+	if err != nil || !slices.Equal(lines, []string{"NO DATA"}) {
+		t.Errorf("got %v, %v", lines, err)
+	}
+	for _, answer := range []string{"?\r\r>", "CAN ERROR\r\r>", "UNABLE TO CONNECT\r\r>", "BUS INIT: ...ERROR\r\r>"} {
+		if _, err := parseRawAnswer("0A", answer); err == nil {
+			t.Errorf("%q accepted", answer)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package obd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+var ErrNoPrompt = errors.New("obd: adapter didn't finish its answer")
+
+// Port settings elmobd uses. The settings belong to the port, not the handle, so a second handle must not change them.
+const (
+	rawBaud    = 38400
+	rawTimeout = 5 * time.Second
+)
+
+// rawDevice is what Session.Raw hands out
+type rawDevice interface {
+	RawCommander
+	Close() error
+}
+
+// Raw sends plain ELM327 commands on its own handle to the adapter's port. elmobd only runs Mode 01 and keeps its
+// port to itself, so the modes it doesn't know go through here. Both must only be used from the polling goroutine,
+// the adapter can't tell the two handles apart.
+type Raw struct {
+	port *serial.Port
+}
+
+func OpenRaw(path string) (*Raw, error) {
+	port, err := serial.OpenPort(&serial.Config{Name: path, Baud: rawBaud, ReadTimeout: rawTimeout})
+	if err != nil {
+		return nil, err
+	}
+	return &Raw{port: port}, nil
+}
+
+// Sends the command and returns the answer lines without echo and prompt. Answers the ELM327 gives instead of
+// data (?, CAN ERROR, UNABLE TO CONNECT, ...) are returned as error, NO DATA is a normal answer.
+func (r *Raw) RunRawCommand(command string) ([]string, error) {
+	if _, err := r.port.Write([]byte(command + "\r")); err != nil {
+		return nil, err
+	}
+
+	answer := []byte{}
+	buffer := make([]byte, 128)
+	for !strings.Contains(string(answer), ">") {
+		n, err := r.port.Read(buffer)
+		// the read timeout shows up as EOF
+		if err == io.EOF {
+			return nil, ErrNoPrompt
+		}
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, buffer[:n]...)
+	}
+	return parseRawAnswer(command, string(answer))
+}
+
+func (r *Raw) Close() error {
+	return r.port.Close()
+}
+
+// Splits an answer into lines and drops echo, prompt and the searching note
+func parseRawAnswer(command string, answer string) ([]string, error) {
+	answer, _, _ = strings.Cut(answer, ">")
+	lines := []string{}
+	for _, line := range strings.FieldsFunc(answer, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || line == command || strings.HasPrefix(line, "SEARCHING") {
+			continue
+		}
+		upper := strings.ToUpper(line)
+		if line == "?" || strings.Contains(upper, "ERROR") || strings.Contains(upper, "UNABLE TO CONNECT") ||
+			strings.Contains(upper, "STOPPED") {
+			return nil, fmt.Errorf("obd: %s answered %q", command, line)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// mockRaw answers like a car with one stored and one pending code, for test://
+type mockRaw struct {
+	cleared bool
+}
+
+func (m *mockRaw) RunRawCommand(command string) ([]string, error) {
+	switch {
+	case command == "ATDPN":
+		return []string{"A6"}, nil
+	case command == "04":
+		m.cleared = true
+		return []string{"44"}, nil
+	case m.cleared:
+		return []string{"NO DATA"}, nil
+	case command == "03":
+		return []string{"43 01 01 33"}, nil
+	case command == "07":
+		return []string{"47 01 03 00"}, nil
+	}
+	return []string{"NO DATA"}, nil
+}
+
+func (m *mockRaw) Close() error {
+	return nil
+}
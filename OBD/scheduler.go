@@ -0,0 +1,180 @@
+package obd
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rzetterberg/elmobd"
+)
+
+// Scheduler decides which PIDs to ask for next. Every PID gets more urgent the longer it waits past its rate,
+// weighted by its priority, so speed and RPM come first but coolant still gets its turn.
+// The due PIDs go to the adapter together, as many as fit into the time the fastest PID allows.
+type Scheduler struct {
+	Session *Session
+	PIDs    []PID
+	// PIDs per request, a CAN request holds six, older protocols only one
+	MaxBatch int
+	// Longest pause after errors
+	MaxBackoff time.Duration
+
+	mu            sync.Mutex
+	telemetry     Telemetry
+	last          [metricCount]time.Time
+	latency       time.Duration
+	failures      int
+	batchFailures int
+	oneByOne      bool
+	// Session.Connects at the last Poll, a new device starts over with latency and batch size
+	connects int
+}
+
+func NewScheduler(session *Session) *Scheduler {
+	return &Scheduler{
+		Session:    session,
+		PIDs:       PIDs,
+		MaxBatch:   6,
+		MaxBackoff: 5 * time.Second,
+	}
+}
+
+// Returns the PIDs for the next request, most urgent first
+func (s *Scheduler) getBatch(now time.Time) []PID {
+	type candidate struct {
+		pid     PID
+		urgency float64
+	}
+	candidates := []candidate{}
+	fastest := time.Duration(0)
+	for _, pid := range s.PIDs {
+		if !s.Session.IsSupported(pid.Metric) {
+			continue
+		}
+		if fastest == 0 || pid.Rate < fastest {
+			fastest = pid.Rate
+		}
+		overdue := float64(now.Sub(s.last[pid.Metric])) / float64(pid.Rate)
+		if overdue >= 1 {
+			candidates = append(candidates, candidate{pid, overdue * float64(pid.Priority)})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.urgency > b.urgency:
+			return -1
+		case a.urgency < b.urgency:
+			return 1
+		}
+		return 0
+	})
+
+	// A long request would hold up the fast PIDs, so only take what the adapter answers within the fastest rate
+	size := s.MaxBatch
+	if s.oneByOne {
+		size = 1
+	}
+	if s.latency > 0 {
+		size = min(size, max(1, int(fastest/s.latency)))
+	}
+	batch := []PID{}
+	for i := 0; i < len(candidates) && i < size; i++ {
+		batch = append(batch, candidates[i].pid)
+	}
+	return batch
+}
+
+// Reads the most urgent PIDs and returns the updated telemetry and which metrics changed
+func (s *Scheduler) Poll(now time.Time) (Telemetry, []Metric, error) {
+	// Connecting and discovery must not count as latency, so the device is opened before anything is timed
+	s.Session.Device()
+	connects := s.Session.Connects()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if connects != s.connects {
+		// Maybe another adapter or another car
+		s.connects = connects
+		s.latency = 0
+		s.oneByOne = false
+		s.batchFailures = 0
+	}
+
+	batch := s.getBatch(now)
+	if len(batch) == 0 {
+		return s.telemetry, nil, nil
+	}
+	commands := make([]elmobd.OBDCommand, len(batch))
+	for i, pid := range batch {
+		commands[i] = pid.New()
+	}
+
+	// Unlocked while the adapter works, the UI may ask for stats meanwhile
+	s.mu.Unlock()
+	start := time.Now()
+	response, err := s.Session.Run(commands)
+	elapsed := time.Since(start)
+	s.mu.Lock()
+
+	if err != nil {
+		s.failures++
+		// ECUs on K-Line don't take several PIDs at once, after a few failed batches ask one by one
+		if len(batch) > 1 && Classify(err) != KindDisconnected {
+			s.batchFailures++
+			if s.batchFailures >= 3 && !s.oneByOne {
+				print("OBD batch requests keep failing, polling one PID at a time\n")
+				s.oneByOne = true
+			}
+		}
+		return s.telemetry, nil, err
+	}
+	s.failures = 0
+	s.batchFailures = 0
+
+	// Latency per PID, smoothed
+	perPID := elapsed / time.Duration(len(batch))
+	if s.latency == 0 {
+		s.latency = perPID
+	} else {
+		s.latency = (s.latency*7 + perPID) / 8
+	}
+
+	updated := []Metric{}
+	for i, pid := range batch {
+		s.last[pid.Metric] = now
+		value, err := strconv.ParseFloat(response[i].ValueAsLit(), 64)
+		if err != nil {
+			continue
+		}
+		s.telemetry.Set(pid.Metric, value, now)
+		updated = append(updated, pid.Metric)
+	}
+	return s.telemetry, updated, nil
+}
+
+// Returns how long to wait before the next Poll: until the next PID is due, longer after errors
+func (s *Scheduler) GetWait(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		backoff := 100 * time.Millisecond << min(s.failures-1, 6)
+		return min(backoff, s.MaxBackoff)
+	}
+	// nothing to poll, look again in a second
+	wait := time.Second
+	for _, pid := range s.PIDs {
+		if s.Session.IsSupported(pid.Metric) {
+			wait = min(wait, s.last[pid.Metric].Add(pid.Rate).Sub(now))
+		}
+	}
+	return max(wait, 0)
+}
+
+// Returns the smoothed adapter round trip per PID
+func (s *Scheduler) Latency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency
+}
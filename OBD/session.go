@@ -0,0 +1,223 @@
+package obd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rzetterberg/elmobd"
+)
+
+// State of the connection to the car
+type State int
+
+const (
+	StateConnecting State = iota
+	StateConnected
+	// The adapter answers but the ECU doesn't, e.g. ignition off
+	StateNoData
+	StateDisconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateNoData:
+		return "no data"
+	case StateDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// ErrorKind says what to do about a failed command
+type ErrorKind int
+
+const (
+	// Garbled or unexpected answer, just try again
+	KindUnknown ErrorKind = iota
+	// NO DATA or STOPPED, the ECU skipped this request
+	KindNoData
+	// BUS INIT, CAN ERROR, UNABLE TO CONNECT: the adapter can't talk to the ECU
+	KindBus
+	// The serial port is gone, the device has to be opened again
+	KindDisconnected
+)
+
+// Returns the kind of an elmobd error. elmobd passes the ELM327 answer and the serial errors on as text,
+// so this goes by the message.
+func Classify(err error) ErrorKind {
+	message := strings.ToUpper(err.Error())
+	switch {
+	// serial errors first, "input/output error" would look like a bus error otherwise
+	case strings.Contains(message, "EOF"), strings.Contains(message, "INPUT/OUTPUT"),
+		strings.Contains(message, "NO SUCH FILE"), strings.Contains(message, "NO SUCH DEVICE"),
+		strings.Contains(message, "BROKEN PIPE"), strings.Contains(message, "BAD FILE DESCRIPTOR"),
+		strings.Contains(message, "DEVICE NOT CONFIGURED"):
+		return KindDisconnected
+	case strings.Contains(message, "NO DATA"), strings.Contains(message, "STOPPED"):
+		return KindNoData
+	case strings.Contains(message, "BUS INIT"), strings.Contains(message, "BUS BUSY"),
+		strings.Contains(message, "BUS ERROR"), strings.Contains(message, "CAN ERROR"),
+		strings.Contains(message, "RX ERROR"), strings.Contains(message, "UNABLE TO CONNECT"):
+		return KindBus
+	}
+	return KindUnknown
+}
+
+// Session keeps an ELM327 connection alive: it retries failed commands and opens the device again
+// after a disconnect instead of giving up
+type Session struct {
+	Path  string
+	Debug bool
+	// Failed commands in a row before the device is opened again
+	MaxErrors int
+	// Wait between reconnect attempts, doubles up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	mu        sync.Mutex
+	device    *elmobd.Device
+	state     State
+	lastErr   error
+	errors    int
+	supported *elmobd.SupportedCommands
+	connects  int
+	raw       rawDevice
+}
+
+func NewSession(path string) *Session {
+	return &Session{
+		Path:       path,
+		MaxErrors:  5,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// Returns the connection state and the last error
+func (s *Session) State() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, s.lastErr
+}
+
+func (s *Session) setState(state State, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.lastErr = err
+}
+
+// Opens the device, blocks until it works
+func (s *Session) Connect() *elmobd.Device {
+	backoff := s.Backoff
+	for {
+		s.setState(StateConnecting, nil)
+		device, err := elmobd.NewDevice(s.Path, s.Debug)
+		if err == nil {
+			s.mu.Lock()
+			s.device = device
+			s.errors = 0
+			s.connects++
+			s.mu.Unlock()
+			s.discover(device)
+			s.setState(StateConnected, nil)
+			return device
+		}
+		print("OBD connect failed: ", err.Error(), "\n")
+		s.setState(StateDisconnected, err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, s.MaxBackoff)
+	}
+}
+
+// Returns the open device, connects first if there is none
+func (s *Session) Device() *elmobd.Device {
+	s.mu.Lock()
+	device := s.device
+	s.mu.Unlock()
+	if device == nil {
+		return s.Connect()
+	}
+	return device
+}
+
+// Returns a raw handle to the adapter for the modes elmobd doesn't know, opened on first use.
+// test:// gets a fake car with trouble codes.
+func (s *Session) Raw() (RawCommander, error) {
+	// elmobd sets the adapter up, so it goes first
+	s.Device()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.raw != nil {
+		return s.raw, nil
+	}
+	if strings.HasPrefix(s.Path, "test://") {
+		s.raw = &mockRaw{}
+		return s.raw, nil
+	}
+	raw, err := OpenRaw(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.raw = raw
+	return raw, nil
+}
+
+// Returns how often the device was opened, it changes with every reconnect
+func (s *Session) Connects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connects
+}
+
+// Runs the commands, on errors the session state is updated and the device reopened if needed.
+// The error is returned so the caller can skip this round.
+func (s *Session) Run(commands []elmobd.OBDCommand) ([]elmobd.OBDCommand, error) {
+	response, err := s.Device().RunManyOBDCommands(commands)
+	if err == nil {
+		s.mu.Lock()
+		s.errors = 0
+		s.mu.Unlock()
+		s.setState(StateConnected, nil)
+		return response, nil
+	}
+	s.Fail(err)
+	return nil, err
+}
+
+// Records a failed command, also for callers that talk to the device themselves
+func (s *Session) Fail(err error) {
+	kind := Classify(err)
+	s.mu.Lock()
+	s.errors++
+	tooMany := s.errors >= s.MaxErrors
+	s.mu.Unlock()
+
+	switch {
+	case kind == KindDisconnected || (tooMany && kind != KindNoData):
+		print("OBD reconnecting: ", err.Error(), "\n")
+		s.setState(StateDisconnected, err)
+		// elmobd has no Close, the old port is left to the garbage collector
+		s.mu.Lock()
+		s.device = nil
+		if s.raw != nil {
+			s.raw.Close()
+			s.raw = nil
+		}
+		s.mu.Unlock()
+		time.Sleep(s.Backoff)
+	case kind == KindNoData || kind == KindBus:
+		s.setState(StateNoData, err)
+		if tooMany {
+			// ignition is probably off, don't hammer the adapter
+			time.Sleep(s.Backoff)
+		}
+	default:
+		s.setState(StateConnected, err)
+	}
+}
@@ -0,0 +1,30 @@
+package obd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		message string
+		want    ErrorKind
+	}{
+		{"EOF", KindDisconnected},
+		{"read /dev/ttyUSB0: input/output error", KindDisconnected},
+		{"open /dev/rfcomm0: no such file or directory", KindDisconnected},
+		{"write /dev/ttyUSB0: broken pipe", KindDisconnected},
+		{"NO DATA", KindNoData},
+		{"STOPPED", KindNoData},
+		{"BUS INIT: ...ERROR", KindBus},
+		{"CAN ERROR", KindBus},
+		{"UNABLE TO CONNECT", KindBus},
+		{"Failed parsing 41 0C", KindUnknown},
+		{"obd: adapter didn't finish its answer", KindUnknown},
+	}
+	for _, test := range tests {
+		if got := Classify(errors.New(test.message)); got != test.want {
+			t.Errorf("Classify(%q) = %d, want %d", test.message, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package obd
+
+import (
+	"time"
+
+	"github.com/rzetterberg/elmobd"
+)
+
+// Metric is one value we read from the car
+type Metric int
+
+const (
+	MetricRPM Metric = iota
+	MetricSpeed
+	MetricThrottle
+	MetricEngineLoad
+	MetricIntakePressure
+	MetricMAF
+	MetricCoolant
+	MetricRuntime
+	metricCount
+)
+
+// PID describes how to read a metric and how often
+type PID struct {
+	Metric Metric
+	New    func() elmobd.OBDCommand
+	// How often it should be polled, the needle needs RPM fast, coolant changes over minutes
+	Rate time.Duration
+	// Who goes first when the adapter can't keep up with all rates
+	Priority int
+}
+
+// Everything the adapter answered in onlyFilterSupported.txt that is worth showing
+var PIDs = []PID{
+	{MetricRPM, func() elmobd.OBDCommand { return elmobd.NewEngineRPM() }, 160 * time.Millisecond, 3},
+	{MetricSpeed, func() elmobd.OBDCommand { return elmobd.NewVehicleSpeed() }, 160 * time.Millisecond, 4},
+	{MetricThrottle, func() elmobd.OBDCommand { return elmobd.NewThrottlePosition() }, 250 * time.Millisecond, 2},
+	{MetricEngineLoad, func() elmobd.OBDCommand { return elmobd.NewEngineLoad() }, 500 * time.Millisecond, 1},
+	{MetricIntakePressure, func() elmobd.OBDCommand { return elmobd.NewIntakeManifoldPressure() }, 500 * time.Millisecond, 1},
+	{MetricMAF, func() elmobd.OBDCommand { return elmobd.NewMafAirFlowRate() }, 500 * time.Millisecond, 1},
+	{MetricCoolant, func() elmobd.OBDCommand { return elmobd.NewCoolantTemperature() }, 5 * time.Second, 1},
+	{MetricRuntime, func() elmobd.OBDCommand { return elmobd.NewRuntimeSinceStart() }, 10 * time.Second, 1},
+}
+
+// Returns the PID of a metric
+func GetPID(metric Metric) PID {
+	for _, pid := range PIDs {
+		if pid.Metric == metric {
+			return pid
+		}
+	}
+	return PID{Metric: metric}
+}
+
+// Telemetry is the latest value of every metric
+type Telemetry struct {
+	RPM            float64
+	Speed          float64 // km/h
+	Throttle       float64 // %
+	EngineLoad     float64 // %
+	IntakePressure float64 // kPa
+	MAF            float64 // g/s
+	Coolant        float64 // °C
+	Runtime        time.Duration
+	// When each metric was read, zero if never
+	Updated [metricCount]time.Time
+}
+
+func (t *Telemetry) Set(metric Metric, value float64, at time.Time) {
+	switch metric {
+	case MetricRPM:
+		t.RPM = value
+	case MetricSpeed:
+		t.Speed = value
+	case MetricThrottle:
+		t.Throttle = value
+	case MetricEngineLoad:
+		t.EngineLoad = value
+	case MetricIntakePressure:
+		t.IntakePressure = value
+	case MetricMAF:
+		t.MAF = value
+	case MetricCoolant:
+		t.Coolant = value
+	case MetricRuntime:
+		t.Runtime = time.Duration(value) * time.Second
+	}
+	t.Updated[metric] = at
+}
+
+func (t *Telemetry) Get(metric Metric) float64 {
+	switch metric {
+	case MetricRPM:
+		return t.RPM
+	case MetricSpeed:
+		return t.Speed
+	case MetricThrottle:
+		return t.Throttle
+	case MetricEngineLoad:
+		return t.EngineLoad
+	case MetricIntakePressure:
+		return t.IntakePressure
+	case MetricMAF:
+		return t.MAF
+	case MetricCoolant:
+		return t.Coolant
+	case MetricRuntime:
+		return t.Runtime.Seconds()
+	}
+	return 0
+}
+
+// Returns whether the metric was read at all
+func (t *Telemetry) Has(metric Metric) bool {
+	return !t.Updated[metric].IsZero()
+}
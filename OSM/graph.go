@@ -0,0 +1,264 @@
+package osm
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	"io"
+	"math"
+	"os"
+	"runtime"
+
+	"github.com/qedus/osmpbf"
+)
+
+// Grid cell size in degrees for the segment index, ~500 m
+const cellSize = 0.005
+
+// Roads a car can drive on
+var routable = map[string]bool{
+	"motorway": true, "motorway_link": true,
+	"trunk": true, "trunk_link": true,
+	"primary": true, "primary_link": true,
+	"secondary": true, "secondary_link": true,
+	"tertiary": true, "tertiary_link": true,
+	"unclassified": true, "residential": true,
+	"living_street": true, "service": true, "road": true,
+}
+
+type Way struct {
+	ID      int64
+	Name    string
+	Highway string
+	// 1 only along the nodes, -1 only against them, 0 both ways
+	Oneway int
+	Tags   map[string]string
+	// Indices into Graph.Nodes
+	Nodes []int32
+}
+
+type edge struct {
+	to     int32
+	length float64
+}
+
+// Segment is the part of a way between two consecutive nodes
+type Segment struct {
+	Way   int32
+	Index int32
+}
+
+// Graph is a routable road network read from an OpenStreetMap extract
+type Graph struct {
+	Nodes [][2]float64
+	Ways  []Way
+
+	ids   map[int64]int32
+	edges [][]edge
+	grid  map[[2]int][]Segment
+}
+
+// Loads all drivable roads of an .osm.pbf file, keep the extract small (e.g. one Regierungsbezirk)
+func Load(path string) (*Graph, error) {
+	g := &Graph{
+		ids:  map[int64]int32{},
+		grid: map[[2]int][]Segment{},
+	}
+
+	// Ways come after the nodes in the file, so read it twice: first ways, then the nodes they need
+	wayNodes := [][]int64{}
+	err := decode(path, func(v any) {
+		way, ok := v.(*osmpbf.Way)
+		if !ok || !routable[way.Tags["highway"]] || len(way.NodeIDs) < 2 {
+			return
+		}
+		g.Ways = append(g.Ways, Way{
+			ID:      way.ID,
+			Name:    way.Tags["name"],
+			Highway: way.Tags["highway"],
+			Oneway:  getOneway(way.Tags),
+			Tags:    way.Tags,
+		})
+		wayNodes = append(wayNodes, way.NodeIDs)
+		for _, id := range way.NodeIDs {
+			g.ids[id] = -1
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = decode(path, func(v any) {
+		node, ok := v.(*osmpbf.Node)
+		if !ok {
+			return
+		}
+		if _, needed := g.ids[node.ID]; needed {
+			g.ids[node.ID] = int32(len(g.Nodes))
+			g.Nodes = append(g.Nodes, [2]float64{node.Lat, node.Lon})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g.edges = make([][]edge, len(g.Nodes))
+	for w := range g.Ways {
+		for _, id := range wayNodes[w] {
+			// extracts cut ways at the border, skip nodes we don't have
+			if index := g.ids[id]; index >= 0 {
+				g.Ways[w].Nodes = append(g.Ways[w].Nodes, index)
+			}
+		}
+		g.addWay(int32(w))
+	}
+	return g, nil
+}
+
+func decode(path string, handle func(any)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := osmpbf.NewDecoder(file)
+	decoder.SetBufferSize(osmpbf.MaxBlobSize)
+	if err := decoder.Start(runtime.GOMAXPROCS(-1)); err != nil {
+		return err
+	}
+	for {
+		v, err := decoder.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		handle(v)
+	}
+}
+
+func getOneway(tags map[string]string) int {
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		return 1
+	case "-1", "reverse":
+		return -1
+	}
+	if tags["junction"] == "roundabout" || tags["highway"] == "motorway" {
+		return 1
+	}
+	return 0
+}
+
+// Adds edges and index entries for all segments of a way
+func (g *Graph) addWay(w int32) {
+	way := g.Ways[w]
+	for i := 0; i+1 < len(way.Nodes); i++ {
+		from := way.Nodes[i]
+		to := way.Nodes[i+1]
+		length := Blitzer.GetDist(g.Nodes[from], g.Nodes[to])
+		if way.Oneway >= 0 {
+			g.edges[from] = append(g.edges[from], edge{to, length})
+		}
+		if way.Oneway <= 0 {
+			g.edges[to] = append(g.edges[to], edge{from, length})
+		}
+
+		segment := Segment{w, int32(i)}
+		for _, cell := range getCells(g.Nodes[from], g.Nodes[to]) {
+			g.grid[cell] = append(g.grid[cell], segment)
+		}
+	}
+}
+
+func getCell(pos [2]float64) [2]int {
+	return [2]int{int(math.Floor(pos[0] / cellSize)), int(math.Floor(pos[1] / cellSize))}
+}
+
+// Returns all cells touched by the bounding box of a segment
+func getCells(a [2]float64, b [2]float64) [][2]int {
+	from := getCell([2]float64{math.Min(a[0], b[0]), math.Min(a[1], b[1])})
+	to := getCell([2]float64{math.Max(a[0], b[0]), math.Max(a[1], b[1])})
+	cells := [][2]int{}
+	for lat := from[0]; lat <= to[0]; lat++ {
+		for lng := from[1]; lng <= to[1]; lng++ {
+			cells = append(cells, [2]int{lat, lng})
+		}
+	}
+	return cells
+}
+
+// Returns start and end node of a segment
+func (g *Graph) GetSegmentNodes(segment Segment) (int32, int32) {
+	way := g.Ways[segment.Way]
+	return way.Nodes[segment.Index], way.Nodes[segment.Index+1]
+}
+
+// Returns length of a segment in km
+func (g *Graph) GetSegmentLength(segment Segment) float64 {
+	from, to := g.GetSegmentNodes(segment)
+	return Blitzer.GetDist(g.Nodes[from], g.Nodes[to])
+}
+
+// Snap is a position projected onto a segment
+type Snap struct {
+	Segment Segment
+	// 0 at the start node, 1 at the end node
+	Fraction float64
+	Pos      [2]float64
+	// Distance between position and road in km
+	Distance float64
+}
+
+// Returns all segments within radius km of pos, projected onto
+func (g *Graph) GetCandidates(pos [2]float64, radius float64) []Snap {
+	// radius in degrees, longitude degrees are shorter
+	dLat := radius / 111.0
+	dLng := radius / (111.0 * math.Cos(pos[0]*math.Pi/180))
+	seen := map[Segment]bool{}
+	snaps := []Snap{}
+	for _, cell := range getCells([2]float64{pos[0] - dLat, pos[1] - dLng}, [2]float64{pos[0] + dLat, pos[1] + dLng}) {
+		for _, segment := range g.grid[cell] {
+			if seen[segment] {
+				continue
+			}
+			seen[segment] = true
+			snap := g.project(segment, pos)
+			if snap.Distance <= radius {
+				snaps = append(snaps, snap)
+			}
+		}
+	}
+	return snaps
+}
+
+// Returns the closest road to pos within radius km
+func (g *Graph) GetNearest(pos [2]float64, radius float64) (Snap, bool) {
+	best := Snap{Distance: math.Inf(1)}
+	for _, snap := range g.GetCandidates(pos, radius) {
+		if snap.Distance < best.Distance {
+			best = snap
+		}
+	}
+	return best, !math.IsInf(best.Distance, 1)
+}
+
+// Projects pos onto a segment, flat earth is fine for a few hundred metres
+func (g *Graph) project(segment Segment, pos [2]float64) Snap {
+	from, to := g.GetSegmentNodes(segment)
+	a := g.Nodes[from]
+	b := g.Nodes[to]
+	scale := math.Cos(pos[0] * math.Pi / 180)
+
+	abx := (b[1] - a[1]) * scale
+	aby := b[0] - a[0]
+	apx := (pos[1] - a[1]) * scale
+	apy := pos[0] - a[0]
+
+	fraction := 0.0
+	if length := abx*abx + aby*aby; length > 0 {
+		fraction = math.Max(0, math.Min(1, (apx*abx+apy*aby)/length))
+	}
+	projected := [2]float64{a[0] + (b[0]-a[0])*fraction, a[1] + (b[1]-a[1])*fraction}
+	return Snap{Segment: segment, Fraction: fraction, Pos: projected, Distance: Blitzer.GetDist(pos, projected)}
+}
@@ -0,0 +1,161 @@
+package osm
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	"math"
+	"sync"
+)
+
+// Match is the road we are driving on
+type Match struct {
+	Snap     Snap
+	WayID    int64
+	Name     string
+	Highway  string
+	MaxSpeed string
+	Tags     map[string]string
+	// Whether we drive along the direction of the way's nodes, only known from the second fix on
+	Forward  bool
+	Directed bool
+}
+
+// Matcher snaps GPS positions onto the road network with a hidden Markov model,
+// the most likely sequence of roads is found with an online Viterbi (Newson & Krumm 2009)
+type Matcher struct {
+	Graph *Graph
+	// GPS noise in km
+	Sigma float64
+	// How much the route may differ from the straight line between two fixes, in km
+	Beta float64
+	// Candidate search radius in km
+	Radius float64
+
+	mu         sync.Mutex
+	candidates []Snap
+	scores     []float64
+	lastPos    [2]float64
+	hasLast    bool
+	current    Match
+	matched    bool
+}
+
+func NewMatcher(graph *Graph) *Matcher {
+	return &Matcher{
+		Graph:  graph,
+		Sigma:  0.01,
+		Beta:   0.05,
+		Radius: 0.05,
+	}
+}
+
+// Feeds a new position into the model, returns the road we are most likely on
+func (m *Matcher) Update(pos [2]float64) (Match, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Standing still adds no information
+	if m.matched && Blitzer.GetDist(m.lastPos, pos) < 0.005 {
+		return m.current, true
+	}
+
+	candidates := m.Graph.GetCandidates(pos, m.Radius)
+	if len(candidates) == 0 {
+		// Off the map, start over once we are back on a road
+		m.candidates = nil
+		m.hasLast = false
+		m.matched = false
+		return Match{}, false
+	}
+
+	scores := make([]float64, len(candidates))
+	straight := Blitzer.GetDist(m.lastPos, pos)
+	connected := false
+	for j, candidate := range candidates {
+		emission := -0.5 * math.Pow(candidate.Distance/m.Sigma, 2)
+		best := math.Inf(-1)
+		for i, previous := range m.candidates {
+			route, err := m.Graph.GetSnapDist(previous, candidate, 2*straight+0.5)
+			if err != nil {
+				continue
+			}
+			transition := -math.Abs(straight-route) / m.Beta
+			best = math.Max(best, m.scores[i]+transition)
+		}
+		if math.IsInf(best, -1) {
+			scores[j] = emission
+		} else {
+			scores[j] = best + emission
+			connected = true
+		}
+	}
+	// Nothing connects to the last fixes (GPS jump, missing road), only the emission counts
+	if !connected {
+		for j, candidate := range candidates {
+			scores[j] = -0.5 * math.Pow(candidate.Distance/m.Sigma, 2)
+		}
+	}
+
+	bestIndex := 0
+	for j := range scores {
+		if scores[j] > scores[bestIndex] {
+			bestIndex = j
+		}
+	}
+	// Keep the numbers small
+	top := scores[bestIndex]
+	for j := range scores {
+		scores[j] -= top
+	}
+
+	m.current = m.getMatch(candidates[bestIndex])
+	// Without a previous fix there is no heading to tell the direction
+	if m.hasLast {
+		m.current.Forward = m.isForward(candidates[bestIndex], Blitzer.GetBearing(m.lastPos, pos))
+		m.current.Directed = true
+	}
+	m.candidates = candidates
+	m.scores = scores
+	m.lastPos = pos
+	m.hasLast = true
+	m.matched = true
+	return m.current, true
+}
+
+// Returns the current road, false if we are not on the map
+func (m *Matcher) Current() (Match, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current, m.matched
+}
+
+func (m *Matcher) getMatch(snap Snap) Match {
+	way := m.Graph.Ways[snap.Segment.Way]
+	return Match{
+		Snap:     snap,
+		WayID:    way.ID,
+		Name:     way.Name,
+		Highway:  way.Highway,
+		MaxSpeed: way.Tags["maxspeed"],
+		Tags:     way.Tags,
+	}
+}
+
+// Returns whether heading follows the direction of the snapped segment
+func (m *Matcher) isForward(snap Snap, heading float64) bool {
+	from, to := m.Graph.GetSegmentNodes(snap.Segment)
+	return Blitzer.GetAngleDiff(Blitzer.GetBearing(m.Graph.Nodes[from], m.Graph.Nodes[to]), heading) <= 90
+}
+
+// Returns the driving distance in km, starting on the matched road instead of just the nearest one,
+// so a blitzer on a parallel road isn't close
+func (m *Matcher) GetRouteDist(from [2]float64, to [2]float64) (float64, error) {
+	current, ok := m.Current()
+	if !ok || Blitzer.GetDist(current.Snap.Pos, from) > m.Radius {
+		return m.Graph.GetRouteDist(from, to)
+	}
+	end, ok := m.Graph.GetNearest(to, SnapRadius)
+	if !ok {
+		return 0, ErrNoRoad
+	}
+	return m.Graph.GetSnapDist(current.Snap, end, MaxRouteDist)
+}
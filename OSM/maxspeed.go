@@ -0,0 +1,220 @@
+package osm
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is the posted speed limit of a road
+type Limit struct {
+	// km/h, 0 means no limit (Autobahn)
+	Vmax  int32
+	Known bool
+	// Variable message signs, the displayed limit may be lower
+	Variable bool
+}
+
+// Implicit German limits, https://wiki.openstreetmap.org/wiki/Speed_limits
+var implicitLimits = map[string]int32{
+	"DE:urban":         50,
+	"DE:rural":         100,
+	"DE:motorway":      0,
+	"DE:trunk":         0,
+	"DE:living_street": 7,
+	"DE:bicycle_road":  30,
+	"DE:zone20":        20,
+	"DE:zone30":        30,
+	"DE:zone:20":       20,
+	"DE:zone:30":       30,
+}
+
+// Returns km/h for a maxspeed value like "50", "30 mph", "DE:urban", "DE:30" (zone:maxspeed) or "none"
+func ParseMaxSpeed(value string) (int32, bool) {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "":
+		return 0, false
+	case "none":
+		return 0, true
+	case "walk":
+		return 7, true
+	}
+	if vmax, ok := implicitLimits[value]; ok {
+		return vmax, true
+	}
+	// zone:maxspeed puts the country before the number
+	if country, number, ok := strings.Cut(value, ":"); ok && len(country) == 2 {
+		value = number
+	}
+	if number, ok := strings.CutSuffix(value, "mph"); ok {
+		mph, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil {
+			return 0, false
+		}
+		return int32(mph*1.609 + 0.5), true
+	}
+	vmax, err := strconv.ParseInt(strings.TrimSuffix(value, " km/h"), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(vmax), true
+}
+
+// Returns the limit on the matched road at the given time
+func GetLimit(match Match, at time.Time) Limit {
+	tags := match.Tags
+	limit := Limit{}
+
+	// Direction specific tags win over the plain one, as long as we know our direction
+	keys := []string{"maxspeed"}
+	if match.Directed {
+		keys = []string{"maxspeed:backward", "maxspeed"}
+		if match.Forward {
+			keys[0] = "maxspeed:forward"
+		}
+	}
+	for _, key := range keys {
+		if vmax, ok := ParseMaxSpeed(tags[key]); ok {
+			limit = Limit{Vmax: vmax, Known: true}
+			break
+		}
+	}
+
+	// No sign, the limit follows from where the road is
+	if !limit.Known {
+		for _, key := range []string{"maxspeed:type", "source:maxspeed", "zone:maxspeed"} {
+			if vmax, ok := ParseMaxSpeed(tags[key]); ok {
+				limit = Limit{Vmax: vmax, Known: true}
+				break
+			}
+		}
+	}
+	if !limit.Known {
+		limit = getHighwayLimit(match.Highway)
+	}
+
+	if vmax, ok := getConditionalLimit(tags["maxspeed:conditional"], at); ok {
+		limit.Vmax = vmax
+		limit.Known = true
+	}
+	if tags["maxspeed"] == "signals" || tags["maxspeed:variable"] != "" {
+		limit.Variable = true
+	}
+	return limit
+}
+
+// Guesses the limit from the road class, residential roads are in towns. Primary to unclassified roads
+// can be 50 in town or 100 outside, so they stay unknown.
+func getHighwayLimit(highway string) Limit {
+	switch highway {
+	case "motorway", "motorway_link":
+		return Limit{Vmax: 0, Known: true}
+	case "living_street":
+		return Limit{Vmax: 7, Known: true}
+	case "residential":
+		return Limit{Vmax: 50, Known: true}
+	}
+	return Limit{}
+}
+
+// Parses "80 @ (22:00-06:00); 30 @ (Mo-Fr 07:00-17:00)" and returns the limit active at the given time.
+// Conditions we can't check (wet, snow, hgv, ...) are ignored.
+func getConditionalLimit(value string, at time.Time) (int32, bool) {
+	for _, rule := range strings.Split(value, ";") {
+		speed, condition, ok := strings.Cut(rule, "@")
+		if !ok {
+			continue
+		}
+		vmax, ok := ParseMaxSpeed(speed)
+		if !ok {
+			continue
+		}
+		condition = strings.Trim(strings.TrimSpace(condition), "()")
+		if matchTimeCondition(condition, at) {
+			return vmax, true
+		}
+	}
+	return 0, false
+}
+
+var weekdays = map[string]time.Weekday{
+	"Mo": time.Monday, "Tu": time.Tuesday, "We": time.Wednesday, "Th": time.Thursday,
+	"Fr": time.Friday, "Sa": time.Saturday, "Su": time.Sunday,
+}
+
+// Matches "22:00-06:00", "Mo-Fr 07:00-17:00" or "Sa,Su 10:00-18:00"
+func matchTimeCondition(condition string, at time.Time) bool {
+	fields := strings.Fields(condition)
+	if len(fields) == 0 || len(fields) > 2 {
+		return false
+	}
+	hours := fields[len(fields)-1]
+	if len(fields) == 2 && !matchWeekdays(fields[0], at.Weekday()) {
+		return false
+	}
+
+	for _, span := range strings.Split(hours, ",") {
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return false
+		}
+		startMinutes, ok1 := parseMinutes(from)
+		endMinutes, ok2 := parseMinutes(to)
+		if !ok1 || !ok2 {
+			return false
+		}
+		now := at.Hour()*60 + at.Minute()
+		if startMinutes <= endMinutes && now >= startMinutes && now < endMinutes {
+			return true
+		}
+		// over midnight
+		if startMinutes > endMinutes && (now >= startMinutes || now < endMinutes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a time of day like "7:30" or "22:00" into minutes since midnight. OSM ends days at "24:00", that is 1440.
+func parseMinutes(clock string) (int, bool) {
+	hour, minute, ok := strings.Cut(clock, ":")
+	if !ok || len(hour) < 1 || len(hour) > 2 || len(minute) != 2 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(hour)
+	m, err2 := strconv.Atoi(minute)
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+func matchWeekdays(days string, day time.Weekday) bool {
+	for _, part := range strings.Split(days, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, ok := weekdays[from]
+		if !ok {
+			return false
+		}
+		if !isRange {
+			if start == day {
+				return true
+			}
+			continue
+		}
+		end, ok := weekdays[to]
+		if !ok {
+			return false
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			if d == day {
+				return true
+			}
+			if d == end {
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package osm
+
+import (
+	Blitzer "FesterBlitzer/Blitzer"
+	"container/heap"
+	"errors"
+	"math"
+)
+
+const (
+	// How far in km a point may be off the road to still be snapped onto it
+	SnapRadius = 0.05
+	// Routes longer than this in km are not searched, the blitzer is too far by road anyway
+	MaxRouteDist = 20.0
+)
+
+var (
+	ErrNoRoad = errors.New("osm: no road near position")
+	ErrTooFar = Blitzer.ErrUnreachable
+)
+
+type queueItem struct {
+	node int32
+	dist float64
+}
+
+type queue []queueItem
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)        { *q = append(*q, x.(queueItem)) }
+func (q *queue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Returns the driving distance in km from one point to another along the road network
+func (g *Graph) GetRouteDist(from [2]float64, to [2]float64) (float64, error) {
+	start, ok := g.GetNearest(from, SnapRadius)
+	if !ok {
+		return 0, ErrNoRoad
+	}
+	end, ok := g.GetNearest(to, SnapRadius)
+	if !ok {
+		return 0, ErrNoRoad
+	}
+	return g.GetSnapDist(start, end, MaxRouteDist)
+}
+
+// Returns the driving distance in km between two snapped positions, searching up to limit km
+func (g *Graph) GetSnapDist(start Snap, end Snap, limit float64) (float64, error) {
+	startWay := g.Ways[start.Segment.Way]
+	endWay := g.Ways[end.Segment.Way]
+
+	// Both on the same segment, only possible forward on oneways
+	if start.Segment == end.Segment {
+		along := (end.Fraction - start.Fraction) * g.GetSegmentLength(start.Segment)
+		if (along >= 0 && startWay.Oneway >= 0) || (along <= 0 && startWay.Oneway <= 0) {
+			return math.Abs(along), nil
+		}
+	}
+
+	dist := map[int32]float64{}
+	q := &queue{}
+	push := func(node int32, d float64) {
+		if old, ok := dist[node]; ok && old <= d {
+			return
+		}
+		dist[node] = d
+		heap.Push(q, queueItem{node, d})
+	}
+
+	// Leave the start segment to its end or start node
+	startFrom, startTo := g.GetSegmentNodes(start.Segment)
+	startLength := g.GetSegmentLength(start.Segment)
+	if startWay.Oneway >= 0 {
+		push(startTo, (1-start.Fraction)*startLength)
+	}
+	if startWay.Oneway <= 0 {
+		push(startFrom, start.Fraction*startLength)
+	}
+
+	// Enter the end segment from its start or end node
+	endFrom, endTo := g.GetSegmentNodes(end.Segment)
+	endLength := g.GetSegmentLength(end.Segment)
+	targets := map[int32]float64{}
+	if endWay.Oneway >= 0 {
+		targets[endFrom] = end.Fraction * endLength
+	}
+	if endWay.Oneway <= 0 {
+		targets[endTo] = math.Min(targetOr(targets, endTo), (1-end.Fraction)*endLength)
+	}
+
+	best := math.Inf(1)
+	for q.Len() > 0 {
+		item := heap.Pop(q).(queueItem)
+		if item.dist > dist[item.node] {
+			continue
+		}
+		if item.dist >= best || item.dist > limit {
+			break
+		}
+		if rest, ok := targets[item.node]; ok {
+			best = math.Min(best, item.dist+rest)
+		}
+		for _, e := range g.edges[item.node] {
+			push(e.to, item.dist+e.length)
+		}
+	}
+	if math.IsInf(best, 1) || best > limit {
+		return 0, ErrTooFar
+	}
+	return best, nil
+}
+
+func targetOr(targets map[int32]float64, node int32) float64 {
+	if d, ok := targets[node]; ok {
+		return d
+	}
+	return math.Inf(1)
+}
@@ -56,7 +56,7 @@ func getBlitzer() {
 	lastPos := [2]float64{48.515966, 8.869765}
 	currPos := [2]float64{48.515276, 8.870355}
 	
-	scanBox := blitzer.GetScanBox(lastPos, currPos)
+	scanBox := blitzer.GetScanBox(lastPos, currPos, blitzer.DefaultLookahead, blitzer.DefaultWidth)
 	// print(scanBox[0][0], scanBox[0][1], scanBox[1][0], scanBox[1][1], scanBox[2][0], scanBox[2][1], scanBox[3][0], scanBox[3][1], "\n")
 	boxStart, boxEnd := blitzer.GetBoundingBox(scanBox)
 
@@ -4,7 +4,9 @@ go 1.22.8
 
 require (
 	github.com/gen2brain/raylib-go/raylib v0.0.0-20241203091912-3b89a24e68d1
+	github.com/qedus/osmpbf v1.2.0
 	github.com/rzetterberg/elmobd v0.0.0-20241205132528-c018f735699b
+	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
 )
 
 replace github.com/rzetterberg/elmobd => ../elmobd
@@ -12,7 +14,7 @@ replace github.com/rzetterberg/elmobd => ../elmobd
 require (
 	github.com/ebitengine/purego v0.8.1 // indirect
 	github.com/gen2brain/raylib-go/easings v0.0.0-20250327103758-b542022337b8 // indirect
-	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07 // indirect
 	golang.org/x/exp v0.0.0-20241204233417-43b7b7cde48d // indirect
 	golang.org/x/sys v0.28.0 // indirect
+	google.golang.org/protobuf v1.26.0 // indirect
 )
@@ -1,25 +1,27 @@
 package main
 
 import (
+	Alert "FesterBlitzer/Alert"
+	Audio "FesterBlitzer/Audio"
+	Raylib "FesterBlitzer/Audio/Raylib"
 	Blitzer "FesterBlitzer/Blitzer"
+	Fusion "FesterBlitzer/Fusion"
+	GPS "FesterBlitzer/GPS"
+	OBD "FesterBlitzer/OBD"
+	OSM "FesterBlitzer/OSM"
 	"flag"
 	"fmt"
 	"log"
 	"math"
-	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
-	"github.com/rzetterberg/elmobd"
 )
 
-type Car struct {
-	rpm   int32
-	speed int32
-}
-
 func drawTriangle(centerX float32, centerY float32, width float32, height float32, color rl.Color) {
 	top := rl.Vector2{X: centerX, Y: centerY - height/2}
 	bottomLeft := rl.Vector2{X: centerX - width/2, Y: centerY + height/2}
@@ -119,19 +121,125 @@ func drawrpm(rpm float32, font rl.Font) {
 	}
 }
 
-func drawSpeed(speed int32, font rl.Font) {
+func drawSpeed(speed int32, color rl.Color, font rl.Font) {
 	//70
 	if speed < 10 {
-		rl.DrawTextEx(font, strconv.FormatInt(int64(speed), 10), rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 35, Y: float32(rl.GetScreenHeight()/2) - 50}, 125, 0, rl.White)
+		rl.DrawTextEx(font, strconv.FormatInt(int64(speed), 10), rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 35, Y: float32(rl.GetScreenHeight()/2) - 50}, 125, 0, color)
 	} else if speed < 100 {
-		rl.DrawTextEx(font, strconv.FormatInt(int64(speed), 10), rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 70, Y: float32(rl.GetScreenHeight()/2) - 50}, 125, 0, rl.White)
+		rl.DrawTextEx(font, strconv.FormatInt(int64(speed), 10), rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 70, Y: float32(rl.GetScreenHeight()/2) - 50}, 125, 0, color)
 	} else {
-		rl.DrawTextEx(font, strconv.FormatInt(int64(speed), 10), rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 105, Y: float32(rl.GetScreenHeight()/2) - 50}, 125, 0, rl.White)
+		rl.DrawTextEx(font, strconv.FormatInt(int64(speed), 10), rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 105, Y: float32(rl.GetScreenHeight()/2) - 50}, 125, 0, color)
 	}
 	rl.DrawTextEx(font, "km/h", rl.Vector2{X: float32(rl.GetScreenWidth()/2) - 55, Y: float32(rl.GetScreenHeight()/2) + 50}, 50, 0, rl.White)
 }
 
-func drawBlitzer(distance float64, vmax int32, speedTexture rl.Texture2D, infinityTexture rl.Texture2D, carSpeed int32, font rl.Font) {
+// Returns the color of the speed for the alert level
+func getAlertColor(state Alert.State) rl.Color {
+	switch state.Level {
+	case Alert.LevelOverspeed:
+		return rl.Orange
+	case Alert.LevelWarning, Alert.LevelCritical:
+		return rl.Red
+	}
+	return rl.White
+}
+
+// Flashes the screen border when we are too fast, faster the worse it gets
+func drawAlert(state Alert.State) {
+	frequency := 0.0
+	switch state.Level {
+	case Alert.LevelOverspeed:
+		frequency = 1
+	case Alert.LevelWarning:
+		frequency = 2
+	case Alert.LevelCritical:
+		frequency = 4
+	default:
+		return
+	}
+
+	if math.Mod(rl.GetTime()*frequency, 1) < 0.5 {
+		rl.DrawRectangleLinesEx(rl.Rectangle{
+			X:      0,
+			Y:      0,
+			Width:  float32(rl.GetScreenWidth()),
+			Height: float32(rl.GetScreenHeight()),
+		}, 12, getAlertColor(state))
+	}
+}
+
+// Draws a small symbol for the blitzer type centered at centerX, centerY
+func drawBlitzerIcon(kind Blitzer.Type, centerX float32, centerY float32, scale float32) {
+	switch kind {
+	case Blitzer.TypeRedLight, Blitzer.TypeRedLightSpeed:
+		// Traffic light
+		rl.DrawRectangleRounded(rl.Rectangle{X: centerX - 10*scale, Y: centerY - 25*scale, Width: 20 * scale, Height: 50 * scale}, 0.4, 0, rl.DarkGray)
+		rl.DrawCircleV(rl.Vector2{X: centerX, Y: centerY - 15*scale}, 6*scale, rl.Red)
+		rl.DrawCircleV(rl.Vector2{X: centerX, Y: centerY}, 6*scale, rl.Fade(rl.Yellow, 0.3))
+		rl.DrawCircleV(rl.Vector2{X: centerX, Y: centerY + 15*scale}, 6*scale, rl.Fade(rl.Green, 0.3))
+	case Blitzer.TypeSectionControl:
+		// Start and end of the section
+		rl.DrawLineEx(rl.Vector2{X: centerX - 20*scale, Y: centerY}, rl.Vector2{X: centerX + 20*scale, Y: centerY}, 3*scale, rl.White)
+		rl.DrawCircleV(rl.Vector2{X: centerX - 20*scale, Y: centerY}, 6*scale, rl.Blue)
+		rl.DrawCircleV(rl.Vector2{X: centerX + 20*scale, Y: centerY}, 6*scale, rl.Blue)
+	case Blitzer.TypeDistance:
+		// Two cars with a gap
+		rl.DrawRectangleRounded(rl.Rectangle{X: centerX - 8*scale, Y: centerY - 25*scale, Width: 16 * scale, Height: 14 * scale}, 0.4, 0, rl.White)
+		rl.DrawRectangleRounded(rl.Rectangle{X: centerX - 8*scale, Y: centerY + 11*scale, Width: 16 * scale, Height: 14 * scale}, 0.4, 0, rl.White)
+		rl.DrawLineEx(rl.Vector2{X: centerX, Y: centerY - 8*scale}, rl.Vector2{X: centerX, Y: centerY + 8*scale}, 2*scale, rl.Orange)
+	case Blitzer.TypeMobile, Blitzer.TypeSemiStationary:
+		// Camera on a tripod
+		rl.DrawRectangleRounded(rl.Rectangle{X: centerX - 15*scale, Y: centerY - 20*scale, Width: 30 * scale, Height: 18 * scale}, 0.3, 0, rl.White)
+		rl.DrawCircleV(rl.Vector2{X: centerX, Y: centerY - 11*scale}, 5*scale, rl.Black)
+		rl.DrawLineEx(rl.Vector2{X: centerX, Y: centerY - 2*scale}, rl.Vector2{X: centerX - 12*scale, Y: centerY + 22*scale}, 2*scale, rl.White)
+		rl.DrawLineEx(rl.Vector2{X: centerX, Y: centerY - 2*scale}, rl.Vector2{X: centerX + 12*scale, Y: centerY + 22*scale}, 2*scale, rl.White)
+	case Blitzer.TypeTrafficJamEnd:
+		// Warning sign
+		drawTriangle(centerX, centerY, 50*scale, 44*scale, rl.Red)
+		drawTriangle(centerX, centerY+4*scale, 34*scale, 30*scale, rl.White)
+	default:
+		// Fixed speed camera
+		rl.DrawRectangleRounded(rl.Rectangle{X: centerX - 12*scale, Y: centerY - 25*scale, Width: 24 * scale, Height: 50 * scale}, 0.3, 0, rl.Gray)
+		rl.DrawCircleV(rl.Vector2{X: centerX, Y: centerY - 10*scale}, 6*scale, rl.Black)
+	}
+}
+
+// Draws age and confidence of a reported mobile blitzer left of the sign
+func drawConfidence(blitzer Blitzer.Blitzer, font rl.Font) {
+	age := blitzer.GetAge(time.Now())
+	if age > 0 {
+		text := fmt.Sprintf("%.0fm", age.Minutes())
+		if age >= time.Hour {
+			text = fmt.Sprintf("%.0fh", age.Hours())
+		}
+		rl.DrawTextEx(font, text, rl.Vector2{X: 480, Y: 105}, 25, 0, rl.White)
+	}
+
+	for i := 0; i < 3; i++ {
+		color := rl.DarkGray
+		if i < blitzer.GetConfidence() {
+			color = rl.Green
+		}
+		rl.DrawCircle(int32(487+i*14), 145, 5, color)
+	}
+}
+
+// Draws the posted limit sign, infinity if there is none or we don't know it
+func drawPostedLimit(posted OSM.Limit, speedTexture rl.Texture2D, infinityTexture rl.Texture2D, position rl.Vector2, scale float32, font rl.Font) {
+	if posted.Known && posted.Vmax > 0 {
+		rl.DrawTextureEx(speedTexture, position, 0, scale, rl.White)
+		rl.DrawTextEx(font, strconv.FormatInt(int64(posted.Vmax), 10), rl.Vector2{X: position.X + 24*scale, Y: position.Y + 26*scale}, 50*scale, 0, rl.Black)
+	} else {
+		rl.DrawTextureEx(infinityTexture, position, 0, scale, rl.White)
+	}
+	if posted.Variable {
+		// Variable signs may show less
+		rl.DrawTextEx(font, "VAR", rl.Vector2{X: position.X + 119*scale, Y: position.Y + 35*scale}, 25*scale, 0, rl.Yellow)
+	}
+}
+
+func drawBlitzer(blitzer Blitzer.Blitzer, posted OSM.Limit, speedTexture rl.Texture2D, infinityTexture rl.Texture2D, carSpeed int32, font rl.Font) {
+	vmax := blitzer.Vmax
 	centerY := 400.0
 	topWidth := 175.0
 	bottomWidth := 200.0
@@ -139,24 +247,40 @@ func drawBlitzer(distance float64, vmax int32, speedTexture rl.Texture2D, infini
 	fillCount := float64(-1)
 
 	if carSpeed >= 10 {
-		if vmax == 0 {
+		noBlitzer := vmax == 0 && blitzer.Type == Blitzer.TypeUnknown
+		if !noBlitzer && posted.Known {
+			// The blitzer, or the offline warning, takes the big sign, the posted limit goes small above it
+			drawPostedLimit(posted, speedTexture, infinityTexture, rl.Vector2{X: 680, Y: 20}, 0.5, font)
+		}
+
+		if noBlitzer {
+			// No blitzer, show the posted limit instead
 			fillCount = -1
-			rl.DrawTexture(infinityTexture, 551, 80, rl.White)
+			drawPostedLimit(posted, speedTexture, infinityTexture, rl.Vector2{X: 551, Y: 80}, 1, font)
 		} else if vmax == -1 {
 			fillCount = 6
 			rl.DrawTexture(speedTexture, 551, 80, rl.White)
 			rl.DrawTextEx(font, "0", rl.Vector2{X: 590, Y: 106}, 40, 0, rl.Black)
 		} else {
-			fillCount = ((1 - distance) * 5)
+			fillCount = ((1 - blitzer.Distance/blitzer.AlertDistance) * 5)
 
 			// With Distance
 			// rl.DrawTextEx(font, strconv.FormatFloat(distance*1000, 'f', 0, 64), rl.Vector2{X: 584, Y: 173}, 30, 0, rl.White)
 			// rl.DrawTexture(speedTexture, 551, 65, rl.White)
 			// rl.DrawTextEx(font, strconv.FormatInt(int64(vmax), 10), rl.Vector2{X: 581, Y: 98}, 50, 0, rl.Black)
 
-			// Without Distance
-			rl.DrawTexture(speedTexture, 551, 80, rl.White)
-			rl.DrawTextEx(font, strconv.FormatInt(int64(vmax), 10), rl.Vector2{X: 575, Y: 106}, 50, 0, rl.Black)
+			if vmax == 0 {
+				// Nothing to put on a sign, e.g. red light or traffic jam end
+				drawBlitzerIcon(blitzer.Type, 601, 130, 1.6)
+			} else {
+				// Without Distance
+				rl.DrawTexture(speedTexture, 551, 80, rl.White)
+				rl.DrawTextEx(font, strconv.FormatInt(int64(vmax), 10), rl.Vector2{X: 575, Y: 106}, 50, 0, rl.Black)
+				drawBlitzerIcon(blitzer.Type, 690, 130, 1)
+			}
+			if !blitzer.Fixed {
+				drawConfidence(blitzer, font)
+			}
 
 		}
 
@@ -190,106 +314,270 @@ func drawBlitzer(distance float64, vmax int32, speedTexture rl.Texture2D, infini
 	}
 }
 
-func getBlitzer(BlitzerChannel chan<- Blitzer.Blitzer) {
-	count := 0
-	for {
-		// getPos() braucht man halt und noch LastPos speichern vor schreiben vom Blitzer in den channel
-		// Blitzer types noch casen (6 ist z.B. Abstandsmessung)
-
-		// HEK nach Norden
-		// lastPos := [2]float64{49.0161, 8.3980}
-		// currPos := [2]float64{49.0189, 8.3974}
-
-		positions := [][2]float64{
-			{48.521266, 8.868477},
-			{48.518950, 8.869078},
-			{48.517287, 8.868842},
-			{48.515966, 8.869765},
-			{48.515276, 8.870355},
-			{48.512568, 8.871718},
-			{48.510862, 8.871846},
-			{48.509369, 8.872361},
-			{48.508445, 8.873134},
-		}
-
-		// Hailfingen nach Seebron
-		lastPos := positions[count]
-		currPos := positions[count+1]
-
-		scanBox := Blitzer.GetScanBox(lastPos, currPos)
-		boxStart, boxEnd := Blitzer.GetBoundingBox(scanBox)
-
-		url := fmt.Sprintf("https://cdn2.atudo.net/api/4.0/pois.php?type=22,26,20,101,102,103,104,105,106,107,108,109,110,111,112,113,115,117,114,ts,0,1,2,3,4,5,6,21,23,24,25,29,vwd,traffic&z=17&box=%f,%f,%f,%f",
-			boxStart[0], boxStart[1], boxEnd[0], boxEnd[1])
+// Draws the slow engine values in the bottom right corner, only those the car answered
+func drawTelemetry(telemetry OBD.Telemetry, font rl.Font) {
+	// the font only has ASCII, so no degree sign
+	lines := []struct {
+		metric OBD.Metric
+		text   string
+	}{
+		{OBD.MetricCoolant, fmt.Sprintf("COOL %3.0fC", telemetry.Coolant)},
+		{OBD.MetricEngineLoad, fmt.Sprintf("LOAD %3.0f%%", telemetry.EngineLoad)},
+		{OBD.MetricThrottle, fmt.Sprintf("THR  %3.0f%%", telemetry.Throttle)},
+		{OBD.MetricIntakePressure, fmt.Sprintf("MAP  %3.0fkPa", telemetry.IntakePressure)},
+		{OBD.MetricMAF, fmt.Sprintf("MAF  %5.1fg/s", telemetry.MAF)},
+		{OBD.MetricRuntime, fmt.Sprintf("RUN  %d:%02d", int(telemetry.Runtime.Minutes()), int(telemetry.Runtime.Seconds())%60)},
+	}
+	x := float32(rl.GetScreenWidth()) - 170
+	y := float32(rl.GetScreenHeight()) - 10
+	for i := len(lines) - 1; i >= 0; i-- {
+		if !telemetry.Has(lines[i].metric) {
+			continue
+		}
+		y -= 22
+		rl.DrawTextEx(font, lines[i].text, rl.Vector2{X: x, Y: y}, 20, 0, rl.Gray)
+	}
+}
 
-		client := http.Client{
-			Timeout: time.Second * 15,
+// Shows the engine light with the first trouble codes, pending codes in gray, and when the codes can't be read
+func drawDiagnostics(status OBD.DiagnosticStatus, clearPending bool, font rl.Font) {
+	x := float32(rl.GetScreenWidth()/2) - 110
+	y := float32(10)
+	if status.MIL {
+		text := "CHECK ENGINE"
+		if status.Count > 0 {
+			text += fmt.Sprintf(" %d", status.Count)
+		}
+		rl.DrawTextEx(font, text, rl.Vector2{X: x, Y: y}, 25, 0, rl.Orange)
+		y += 28
+	}
+	for i, dtc := range status.Codes {
+		if i == 2 {
+			break
+		}
+		color := rl.Orange
+		if dtc.Kind != OBD.DTCStored {
+			color = rl.Gray
+		}
+		description := dtc.Description
+		if len(description) > 28 {
+			description = description[:28]
 		}
+		rl.DrawTextEx(font, dtc.Code+" "+description, rl.Vector2{X: x - 60, Y: y}, 16, 0, color)
+		y += 18
+	}
+	if !status.CanRead && !status.Checked.IsZero() {
+		rl.DrawTextEx(font, "Codes unreadable", rl.Vector2{X: x, Y: y}, 16, 0, rl.Gray)
+		y += 18
+	}
+	if clearPending {
+		rl.DrawTextEx(font, "Clear codes? Y", rl.Vector2{X: x, Y: y}, 20, 0, rl.Yellow)
+	}
+}
 
-		resp, err := client.Get(url)
-		if err != nil {
-			print("INTERNET OFF \n")
+// Shows when the car doesn't answer, just the adapter latency while everything is fine
+func drawOBDState(state OBD.State, latency time.Duration, font rl.Font) {
+	color := rl.Yellow
+	switch state {
+	case OBD.StateConnected:
+		rl.DrawTextEx(font, fmt.Sprintf("OBD %dms", latency.Milliseconds()), rl.Vector2{X: 10, Y: 35}, 20, 0, rl.DarkGray)
+		return
+	case OBD.StateNoData:
+		color = rl.Orange
+	case OBD.StateDisconnected:
+		color = rl.Red
+	}
+	rl.DrawTextEx(font, "OBD "+state.String(), rl.Vector2{X: 10, Y: 35}, 20, 0, color)
+}
+
+// Draws our average speed while inside a section control
+func drawSection(state Blitzer.SectionState, font rl.Font) {
+	if !state.Active {
+		return
+	}
+	color := rl.Green
+	if state.TooFast {
+		color = rl.Red
+	}
+
+	x := float32(rl.GetScreenWidth()/2) - 120
+	y := float32(rl.GetScreenHeight()) - 70
+	rl.DrawTextEx(font, fmt.Sprintf("AVG %.0f/%d", state.Average, state.Vmax), rl.Vector2{X: x, Y: y}, 40, 0, color)
+
+	// Progress through the section
+	rl.DrawRectangle(int32(x), int32(y)+45, 240, 6, rl.DarkGray)
+	if state.Driven+state.Remaining > 0 {
+		progress := state.Driven / (state.Driven + state.Remaining)
+		rl.DrawRectangle(int32(x), int32(y)+45, int32(240*progress), 6, color)
+	}
+}
+
+func getBlitzer(BlitzerChannel chan<- Blitzer.Blitzer, SectionChannel chan<- Blitzer.SectionState, position GPS.Provider, provider Blitzer.Provider, matcher *OSM.Matcher, maxMobileAge time.Duration) {
+	section := Blitzer.SectionTracker{}
+	for {
+		pos, ok := position.Position()
+		if !ok {
+			print("NO GPS FIX \n")
 			BlitzerChannel <- Blitzer.Blitzer{Vmax: -1}
 			time.Sleep(time.Second)
 			continue
 		}
-		response := Blitzer.Decode(resp)
+		lastPos := pos.LastPos
+		currPos := pos.CurrPos
+
+		// Look further ahead the faster we go, and narrower where parallel roads are close
+		highway := ""
+		if matcher != nil {
+			if match, onRoad := matcher.Current(); onRoad {
+				highway = match.Highway
+			}
+		}
+		lookahead, width := Blitzer.GetScanSize(pos.Speed, highway)
+		scanBox := Blitzer.GetScanBox(lastPos, currPos, lookahead, width)
+		boxStart, boxEnd := Blitzer.GetBoundingBox(scanBox)
 
-		if response == nil {
-			print("Error decoding \n")
+		heading := Blitzer.GetBearing(lastPos, currPos)
+
+		Blitzers, err := provider.GetBlitzers(boxStart, boxEnd)
+		Blitzer.SetDistances(Blitzers, currPos)
+		if matcher != nil {
+			Blitzer.SetRoadDistances(Blitzers, currPos, matcher)
+		}
+		// Keep tracking a section control even without network
+		SectionChannel <- section.Update(Blitzers, currPos, heading, pos.Time)
+		if err != nil {
+			print("INTERNET OFF: ", err.Error(), "\n")
 			BlitzerChannel <- Blitzer.Blitzer{Vmax: -1}
 			time.Sleep(time.Second)
 			continue
 		}
+		Blitzers = Blitzer.FilterAhead(Blitzers, currPos, heading)
+		Blitzers = Blitzer.FilterAlert(Blitzers, lookahead)
+		Blitzers = Blitzer.FilterExpired(Blitzers, maxMobileAge, time.Now())
 
-		Blitzers := Blitzer.GetBlitzer(*response, currPos)
+		// Dead reckoning moves us between GPS fixes, so look more often than once per fix
 		if len(Blitzers) == 0 {
 			print("No Blitzer found \n")
 			BlitzerChannel <- Blitzer.Blitzer{Vmax: 0}
-			time.Sleep(time.Second)
+			time.Sleep(500 * time.Millisecond)
 		} else {
-			BlitzerChannel <- Blitzer.GetClosestBlitzer(Blitzers)
-			time.Sleep(time.Second)
+			// A confirmed report a few metres further beats a single unconfirmed one
+			BlitzerChannel <- Blitzer.GetBestBlitzer(Blitzers, Blitzer.ConfirmationBand)
+			time.Sleep(500 * time.Millisecond)
 		}
-		if count == 7 {
-			count = 0
-		} else {
-			count += 1
+	}
+}
+
+// Follows the road we are on and sends its posted speed limit
+func getLimit(LimitChannel chan<- OSM.Limit, position GPS.Provider, matcher *OSM.Matcher) {
+	for {
+		pos, ok := position.Position()
+		if ok {
+			match, onRoad := matcher.Update(pos.CurrPos)
+			if onRoad {
+				LimitChannel <- OSM.GetLimit(match, time.Now())
+			} else {
+				LimitChannel <- OSM.Limit{}
+			}
 		}
+		time.Sleep(time.Second)
 	}
 }
 
-func initDevice(path string) *elmobd.Device {
-	serialPath := flag.String(
-		"serial",
-		path,
-		"Path to the serial device to use",
-	)
-	flag.Parse()
-	device, err := elmobd.NewDevice(*serialPath, false)
+// Keeps the cache filled for the next kilometres, getBlitzer then runs on cached tiles
+func prefetchBlitzer(position GPS.Provider, prefetcher *Blitzer.Prefetcher) {
+	for {
+		pos, ok := position.Position()
+		if ok {
+			err := prefetcher.Prefetch(pos.CurrPos, pos.Heading, pos.Speed)
+			if err != nil {
+				print("Prefetch failed: ", err.Error(), "\n")
+			}
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func initProvider(cache *Blitzer.Cache, offlinePath string) Blitzer.Provider {
+	if offlinePath == "" {
+		return cache
+	}
+	offline, err := Blitzer.LoadOffline(offlinePath)
+	if err != nil {
+		print("Check offline database \n")
+		os.Exit(0)
+	}
+	return Blitzer.Fallback{cache, offline}
+}
 
+func initMatcher(osmPath string) *OSM.Matcher {
+	if osmPath == "" {
+		return nil
+	}
+	graph, err := OSM.Load(osmPath)
 	if err != nil {
-		print("Check switch and port \n")
+		print("Check OSM extract \n")
 		os.Exit(0)
 	}
+	return OSM.NewMatcher(graph)
+}
 
-	return device
+func initGPS(path string) GPS.Provider {
+	position, err := GPS.Open(path)
+	if err != nil {
+		print("Check GPS receiver \n")
+		os.Exit(0)
+	}
+
+	go func() {
+		if err := position.Run(); err != nil {
+			log.Print(err)
+		}
+	}()
+	return position
 }
 
-func getCarStats(CarChannel chan<- Car, device *elmobd.Device) {
+func getCarStats(CarChannel chan<- OBD.Telemetry, ClearChannel <-chan bool, scheduler *OBD.Scheduler, diagnostics *OBD.Diagnostics, gps GPS.Provider, position Fusion.Fuser, calibration *Fusion.Calibration, simulated bool) {
 	for {
-		response, err := device.RunManyOBDCommands([]elmobd.OBDCommand{elmobd.NewEngineRPM(), elmobd.NewVehicleSpeed()})
+		// The session reconnects by itself, a failed round is just skipped
+		now := time.Now()
+		telemetry, updated, err := scheduler.Poll(now)
 		if err != nil {
-			log.Fatal(err)
+			time.Sleep(scheduler.GetWait(time.Now()))
+			continue
 		}
 
-		rpm, err := strconv.ParseFloat(response[0].ValueAsLit(), 64)
-		speed, err := strconv.ParseInt(response[1].ValueAsLit(), 10, 32)
+		if slices.Contains(updated, OBD.MetricSpeed) {
+			// Calibrate against the real fixes, not our own extrapolation
+			if pos, ok := gps.Position(); ok {
+				calibration.Add(telemetry.Speed, pos, now)
+			}
+			// The simulated car drives its own speeds, they would pull the position away from the track
+			if !simulated {
+				position.SetSpeed(calibration.Correct(telemetry.Speed), now)
+			}
+		}
+		// The adapter only does one thing at a time, so trouble codes are read in between
+		if diagnostics.IsDue(now) {
+			if err := diagnostics.Check(now); err != nil {
+				print("Reading trouble codes failed: ", err.Error(), "\n")
+			}
+		}
+		select {
+		case <-ClearChannel:
+			if err := diagnostics.Clear(telemetry); err != nil {
+				print("Clearing trouble codes failed: ", err.Error(), "\n")
+			}
+		default:
+		}
 
-		// print("RPM: ", rpm, "\n")
+		// Everything downstream gets the ground speed
+		telemetry.Speed = calibration.Correct(telemetry.Speed)
 
-		CarChannel <- Car{rpm: int32(rpm), speed: int32(speed)}
-		time.Sleep(time.Millisecond * 160)
+		if len(updated) > 0 {
+			CarChannel <- telemetry
+		}
+		time.Sleep(scheduler.GetWait(time.Now()))
 	}
 }
 
@@ -300,18 +588,92 @@ func main() {
 	defer rl.CloseWindow()
 	rl.SetTargetFPS(60)
 
-	// Init OBD2 Reader with path/to/usb
-	// device := initDevice("/dev/tty.usbserial-11340")
-	device := initDevice("test://")
+	// Init OBD2 Reader with path/to/usb, e.g. -serial /dev/tty.usbserial-11340
+	serialPath := flag.String("serial", "test://", "Path to the serial device to use")
+	// GPS receiver, e.g. -gps /dev/ttyACM0 or -gps gpsd://localhost:2947, file:// replays a recorded NMEA log
+	gpsPath := flag.String("gps", "file://Assets/hailfingen.nmea", "Path to the GPS receiver to use")
+	// Offline database of fixed blitzers (.csv, .geojson or saved atudo .json), used without network
+	offlinePath := flag.String("offline", "", "Path to the offline blitzer database")
+	// Directory the atudo responses are cached in, "" keeps them in memory only
+	cachePath := flag.String("cache", "cache", "Path to the blitzer tile cache")
+	// Reports of mobile blitzers older than this are ignored
+	mobileAge := flag.Duration("mobile-age", 2*time.Hour, "Maximum age of mobile blitzer reports")
+	// OpenStreetMap extract for distances along the road instead of straight lines
+	osmPath := flag.String("osm", "", "Path to an .osm.pbf extract of the region")
+	// Subtract the meter tolerance (3 km/h, 3 % from 100 km/h) before warning about overspeed
+	tolerance := flag.Bool("tolerance", true, "Apply the German meter tolerance to overspeed alerts")
+	// Chimes for blitzers, overspeed and section control, M mutes while driving
+	audioOn := flag.Bool("audio", true, "Play warning sounds")
+	volume := flag.Float64("volume", 0.8, "Volume of the warning sounds from 0 to 1")
+	// Spoken announcements like "Blitzer in 500 Metern, 70"
+	voiceSpec := flag.String("voice", "", "Voice for announcements: espeak, piper:<model.onnx> or a directory of wav snippets")
+	lang := flag.String("lang", "de", "Language of the announcements, de or en")
+	// OBD speed reads a few percent high, the correction against GPS is learned while driving and kept here
+	calibrationPath := flag.String("calibration", "calibration.json", "Path to the saved speed calibration")
+	// How GPS and OBD speed are combined, ekf is a Kalman filter, reckoning just moves the last fix on
+	fusion := flag.String("fusion", "ekf", "Position fusion: ekf or reckoning")
+	flag.Parse()
+	session := OBD.NewSession(*serialPath)
+	// Between fixes and in tunnels the position is moved on with the OBD speed
+	gps := initGPS(*gpsPath)
+	defer gps.Close()
+	var position Fusion.Fuser = Fusion.NewFilter(gps)
+	if *fusion == "reckoning" {
+		position = Fusion.NewDeadReckoning(gps)
+	}
 
-	CarStatsChannel := make(chan Car, 2048)
-	go getCarStats(CarStatsChannel, device)
-	carStats := Car{}
+	calibration := Fusion.LoadCalibration(*calibrationPath)
+	// The simulated car has nothing to do with the GPS track
+	simulated := strings.HasPrefix(*serialPath, "test://")
+	calibration.Frozen = simulated
+	if !calibration.Frozen {
+		defer calibration.Save()
+	}
+	CarStatsChannel := make(chan OBD.Telemetry, 2048)
+	scheduler := OBD.NewScheduler(session)
+	diagnostics := OBD.NewDiagnostics(session)
+	// C then Y within a few seconds clears the trouble codes
+	ClearChannel := make(chan bool, 1)
+	clearArmed := time.Time{}
+	go getCarStats(CarStatsChannel, ClearChannel, scheduler, diagnostics, gps, position, calibration, simulated)
+	carStats := OBD.Telemetry{}
 
 	BlitzerChannel := make(chan Blitzer.Blitzer, 2048)
-	go getBlitzer(BlitzerChannel)
+	cache := Blitzer.NewCache(Blitzer.NewAtudo(), *cachePath)
+	go prefetchBlitzer(position, Blitzer.NewPrefetcher(cache))
+	SectionChannel := make(chan Blitzer.SectionState, 2048)
+	sectionState := Blitzer.SectionState{}
+	matcher := initMatcher(*osmPath)
+	go getBlitzer(BlitzerChannel, SectionChannel, position, initProvider(cache, *offlinePath), matcher, *mobileAge)
 	closestBlitzer := Blitzer.Blitzer{}
 
+	LimitChannel := make(chan OSM.Limit, 2048)
+	postedLimit := OSM.Limit{}
+	if matcher != nil {
+		go getLimit(LimitChannel, position, matcher)
+	}
+
+	alertConfig := Alert.DefaultConfig
+	alertConfig.Tolerance = *tolerance
+	alerts := Alert.NewEngine(alertConfig)
+
+	var backend Audio.Backend = &Audio.Null{}
+	if *audioOn {
+		backend = Raylib.NewBackend()
+	}
+	player := Audio.NewPlayer(backend, float32(*volume))
+	defer player.Close()
+	alerts.AddHook(player.OnAlert)
+	var announcer *Audio.Announcer
+	if *voiceSpec != "" {
+		voice, err := Audio.OpenVoice(*voiceSpec, *lang)
+		if err != nil {
+			print("No announcements: ", err.Error(), "\n")
+		} else {
+			announcer = Audio.NewAnnouncer(voice, Audio.GetPhrases(*lang), player)
+		}
+	}
+
 	limitOutline := rl.LoadImage("Assets/SpeedSign.png")
 	rl.ImageResize(limitOutline, 100, 100)
 	speedTexture := rl.LoadTextureFromImage(limitOutline)
@@ -332,19 +694,74 @@ func main() {
 
 		select {
 		case closestBlitzer = <-BlitzerChannel:
+			if announcer != nil {
+				announcer.Update(closestBlitzer)
+			}
 		default:
 		}
 		select {
 		case carStats = <-CarStatsChannel:
 		default:
 		}
+		select {
+		case state := <-SectionChannel:
+			if (state.Active && !sectionState.Active) || (state.TooFast && !sectionState.TooFast) {
+				player.Queue(Audio.SoundSection)
+			}
+			sectionState = state
+		default:
+		}
+		select {
+		case postedLimit = <-LimitChannel:
+		default:
+		}
+
+		// Smoothly interpolate displayedRPM toward carStats.RPM
+		displayedRPM += (float32(carStats.RPM) - displayedRPM) * smoothing
+		carSpeed := int32(math.Round(carStats.Speed))
+
+		blitzerLimit := int32(0)
+		if closestBlitzer.Vmax > 0 {
+			blitzerLimit = closestBlitzer.Vmax
+		}
+		alertState := alerts.Update(float64(carSpeed), blitzerLimit, postedLimit.Vmax)
 
-		// Smoothly interpolate displayedRPM toward carStats.rpm
-		displayedRPM += (float32(carStats.rpm) - displayedRPM) * smoothing
+		if rl.IsKeyPressed(rl.KeyM) {
+			player.ToggleMute()
+		}
+		diagnosticStatus := diagnostics.Status()
+		clearPending := time.Since(clearArmed) < 5*time.Second
+		// Clearing goes through the same raw access as reading, so only offer it once the codes were read
+		if rl.IsKeyPressed(rl.KeyC) && diagnosticStatus.CanRead && (diagnosticStatus.MIL || len(diagnosticStatus.Codes) > 0) {
+			clearArmed = time.Now()
+		}
+		if rl.IsKeyPressed(rl.KeyY) && clearPending {
+			clearArmed = time.Time{}
+			select {
+			case ClearChannel <- true:
+			default:
+			}
+		}
+		// Vmax -1 means no data, 0 without type means no blitzer
+		if closestBlitzer.Vmax > 0 || (closestBlitzer.Vmax == 0 && closestBlitzer.Type != Blitzer.TypeUnknown) {
+			player.SetApproach(closestBlitzer.Distance)
+		} else {
+			player.SetApproach(-1)
+		}
+		player.Update(time.Now())
 
-		drawSpeed(carStats.speed, font)
-		drawrpm(displayedRPM, font)
-		drawBlitzer(closestBlitzer.Distance, closestBlitzer.Vmax, speedTexture, infinityTexture, carStats.speed, font)
+		drawAlert(alertState)
+		drawSpeed(carSpeed, getAlertColor(alertState), font)
+		// Only gauges the car can feed
+		if session.IsSupported(OBD.MetricRPM) {
+			drawrpm(displayedRPM, font)
+		}
+		drawBlitzer(closestBlitzer, postedLimit, speedTexture, infinityTexture, carSpeed, font)
+		drawSection(sectionState, font)
+		drawTelemetry(carStats, font)
+		drawDiagnostics(diagnosticStatus, clearPending, font)
+		obdState, _ := session.State()
+		drawOBDState(obdState, scheduler.Latency(), font)
 
 		rl.EndDrawing()
 	}